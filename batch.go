@@ -0,0 +1,173 @@
+package sockjsclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default batch policy limits
+const (
+	DefaultBatchWindow   = time.Millisecond * 10
+	DefaultBatchMaxBytes = 64 * 1024
+)
+
+// BatchPolicy configures coalescing of outbound messages written to a Client
+// into single sockjs message block frames, reducing e.g. XHR send requests
+type BatchPolicy struct {
+	// Window is how long to wait after the first queued message for others to
+	// coalesce with, before sending. Defaults to DefaultBatchWindow
+	Window time.Duration
+
+	// MaxBytes sends queued messages immediately once their total size
+	// reaches this limit, each frame sent holding no more than it (unless a
+	// single message exceeds it). Defaults to DefaultBatchMaxBytes
+	MaxBytes int
+}
+
+// batcher queues outbound messages, flushing them as a single message block
+type batcher struct {
+	policy  BatchPolicy // resolved batch policy
+	conn    func() Conn // returns conn to flush to
+	pending [][]byte    // queued messages
+	size    int         // total size of queued messages
+	timer   *time.Timer // window flush timer, nil if none pending
+	err     error       // error from last background flush
+	mu      sync.Mutex  // protects pending, size, timer, err
+	fmu     sync.Mutex  // serializes flushes, keeping order
+}
+
+// newBatcher returns a new batcher for policy, flushing to conn
+func newBatcher(policy BatchPolicy, conn func() Conn) *batcher {
+	if policy.Window <= 0 {
+		policy.Window = DefaultBatchWindow
+	}
+	if policy.MaxBytes <= 0 {
+		policy.MaxBytes = DefaultBatchMaxBytes
+	}
+	return &batcher{
+		policy: policy,
+		conn:   conn,
+	}
+}
+
+// add queues msg, flushing immediately should the byte limit be reached. Any error
+// from a previous background flush is returned, msg still being queued behind the
+// messages left unsent by it, for both to be retried on the next flush
+func (b *batcher) add(ctx context.Context, msg []byte) error {
+	b.mu.Lock()
+
+	// Take any background flush error
+	err := b.err
+	b.err = nil
+
+	// Queue message
+	b.pending = append(b.pending, msg)
+	b.size += len(msg)
+	if err == nil && b.size >= b.policy.MaxBytes {
+		b.mu.Unlock()
+		return b.flush(ctx) // flush now as full
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.policy.Window, b.flushBackground)
+	}
+	b.mu.Unlock()
+
+	return err
+}
+
+// flushBackground flushes on window expiry, storing any error to return on next add
+func (b *batcher) flushBackground() {
+	if err := b.flush(context.Background()); err != nil {
+		b.mu.Lock()
+		b.err = err
+		b.mu.Unlock()
+	}
+}
+
+// flush sends any queued messages as message blocks of up to MaxBytes. Should this
+// fail those left unsent are requeued, ahead of any since queued, to be retried
+func (b *batcher) flush(ctx context.Context) error {
+	b.fmu.Lock()
+	defer b.fmu.Unlock()
+
+	// Take queued messages, a retry superseding any background flush error
+	b.mu.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	msgs := b.pending
+	b.pending = nil
+	b.size = 0
+	b.err = nil
+	b.mu.Unlock()
+
+	// Nothing to send
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	// Closed, keep for any final flush
+	conn := b.conn()
+	if conn == nil {
+		b.requeue(msgs, false)
+		return ErrClientNotConnected
+	}
+
+	// Send in blocks, retrying those left unsent after the window
+	for len(msgs) > 0 {
+		n := b.blockLen(msgs)
+		if err := conn.WriteMsgContext(ctx, msgs[:n]...); err != nil {
+			b.requeue(msgs, true)
+			return err
+		}
+		msgs = msgs[n:]
+	}
+	return nil
+}
+
+// blockLen returns the number of msgs to send as the next message block, as
+// many as fit within MaxBytes, but at least one
+func (b *batcher) blockLen(msgs [][]byte) int {
+	n, size := 1, len(msgs[0])
+	for ; n < len(msgs) && size+len(msgs[n]) <= b.policy.MaxBytes; n++ {
+		size += len(msgs[n])
+	}
+	return n
+}
+
+// requeue queues msgs left unsent by a failed flush ahead of those since queued,
+// starting the window timer to flush them again should retry be set
+func (b *batcher) requeue(msgs [][]byte, retry bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(msgs, b.pending...)
+	b.size = 0
+	for _, msg := range b.pending {
+		b.size += len(msg)
+	}
+	if retry && b.timer == nil {
+		b.timer = time.AfterFunc(b.policy.Window, b.flushBackground)
+	}
+}
+
+// discard drops any queued messages and background flush error
+func (b *batcher) discard() {
+	b.mu.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.pending = nil
+	b.size = 0
+	b.err = nil
+	b.mu.Unlock()
+}
+
+// reset clears any background flush error on a new conn, leaving unsent messages queued for it
+func (b *batcher) reset() {
+	b.mu.Lock()
+	b.err = nil
+	b.mu.Unlock()
+}
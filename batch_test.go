@@ -0,0 +1,199 @@
+package sockjsclient
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTestWrite = errors.New("test write failure")
+
+// batchConn is a Conn recording message blocks written, failing writes while fail is set
+type batchConn struct {
+	Conn
+	blocks [][]string    // written message blocks
+	fail   bool          // fail writes
+	writes chan struct{} // notified on each write attempt, unless already pending
+	mu     sync.Mutex    // protects blocks, fail
+}
+
+func newBatchConn() *batchConn {
+	return &batchConn{writes: make(chan struct{}, 16)}
+}
+
+func (conn *batchConn) WriteMsgContext(ctx context.Context, data ...[]byte) error {
+	defer func() {
+		select {
+		case conn.writes <- struct{}{}:
+		default:
+		}
+	}()
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if conn.fail {
+		return errTestWrite
+	}
+	block := []string{}
+	for _, msg := range data {
+		block = append(block, string(msg))
+	}
+	conn.blocks = append(conn.blocks, block)
+	return nil
+}
+
+func (conn *batchConn) setFail(fail bool) {
+	conn.mu.Lock()
+	conn.fail = fail
+	conn.mu.Unlock()
+}
+
+func (conn *batchConn) written() [][]string {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return append([][]string(nil), conn.blocks...)
+}
+
+func TestBatcherFlush(t *testing.T) {
+	conn := newBatchConn()
+	b := newBatcher(BatchPolicy{Window: time.Hour, MaxBytes: 4}, func() Conn { return conn })
+	ctx := context.Background()
+
+	// Coalesced until flushed
+	b.add(ctx, []byte("a"))
+	b.add(ctx, []byte("b"))
+	if blocks := conn.written(); len(blocks) != 0 {
+		t.Fatalf("expected nothing written before flush, got %q", blocks)
+	}
+	if err := b.flush(ctx); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+
+	// Flushed immediately once full
+	b.add(ctx, []byte("cd"))
+	if err := b.add(ctx, []byte("ef")); err != nil {
+		t.Fatalf("error flushing full batch: %v", err)
+	}
+
+	if blocks, exp := conn.written(), [][]string{{"a", "b"}, {"cd", "ef"}}; !reflect.DeepEqual(blocks, exp) {
+		t.Fatalf("written blocks were not as expected: {Expect=%q Written=%q}", exp, blocks)
+	}
+}
+
+func TestBatcherFlushRetry(t *testing.T) {
+	conn := newBatchConn()
+	b := newBatcher(BatchPolicy{Window: time.Hour}, func() Conn { return conn })
+	ctx := context.Background()
+
+	// Failed flush keeps the batch
+	conn.setFail(true)
+	b.add(ctx, []byte("a"))
+	if err := b.flush(ctx); err != errTestWrite {
+		t.Fatalf("expected write failure flushing, got %v", err)
+	}
+
+	// Retried ahead of later messages
+	conn.setFail(false)
+	b.add(ctx, []byte("b"))
+	if err := b.flush(ctx); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+
+	if blocks, exp := conn.written(), [][]string{{"a", "b"}}; !reflect.DeepEqual(blocks, exp) {
+		t.Fatalf("written blocks were not as expected: {Expect=%q Written=%q}", exp, blocks)
+	}
+}
+
+func TestBatcherFlushMaxBytes(t *testing.T) {
+	conn := newBatchConn()
+	b := newBatcher(BatchPolicy{Window: time.Hour, MaxBytes: 4}, func() Conn { return conn })
+	ctx := context.Background()
+
+	// Failed full batch kept
+	conn.setFail(true)
+	b.add(ctx, []byte("ab"))
+	if err := b.add(ctx, []byte("cd")); err != errTestWrite {
+		t.Fatalf("expected write failure flushing full batch, got %v", err)
+	}
+
+	// Sent ahead of later messages, without exceeding the limit
+	conn.setFail(false)
+	if err := b.add(ctx, []byte("ef")); err != nil {
+		t.Fatalf("error flushing full batch: %v", err)
+	}
+
+	if blocks, exp := conn.written(), [][]string{{"ab", "cd"}, {"ef"}}; !reflect.DeepEqual(blocks, exp) {
+		t.Fatalf("written blocks were not as expected: {Expect=%q Written=%q}", exp, blocks)
+	}
+}
+
+func TestBatcherRetry(t *testing.T) {
+	conn := newBatchConn()
+	b := newBatcher(BatchPolicy{Window: time.Millisecond}, func() Conn { return conn })
+	ctx := context.Background()
+
+	// Failed background flush retried after the window, with no further writes
+	conn.setFail(true)
+	b.add(ctx, []byte("a"))
+	<-conn.writes
+	conn.setFail(false)
+
+	deadline := time.Now().Add(time.Second * 5)
+	for len(conn.written()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected failed batch to be retried")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if blocks, exp := conn.written(), [][]string{{"a"}}; !reflect.DeepEqual(blocks, exp) {
+		t.Fatalf("written blocks were not as expected: {Expect=%q Written=%q}", exp, blocks)
+	}
+}
+
+func TestBatcherBackgroundFailure(t *testing.T) {
+	conn := newBatchConn()
+	b := newBatcher(BatchPolicy{Window: time.Hour}, func() Conn { return conn })
+	ctx := context.Background()
+
+	// Background flush fails, the error returned by the next add
+	conn.setFail(true)
+	b.add(ctx, []byte("a"))
+	b.flushBackground()
+	conn.setFail(false)
+	if err := b.add(ctx, []byte("b")); err != errTestWrite {
+		t.Fatalf("expected background write failure adding, got %v", err)
+	}
+
+	// Neither message is lost
+	if err := b.flush(ctx); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+	if blocks, exp := conn.written(), [][]string{{"a", "b"}}; !reflect.DeepEqual(blocks, exp) {
+		t.Fatalf("written blocks were not as expected: {Expect=%q Written=%q}", exp, blocks)
+	}
+}
+
+func TestBatcherReset(t *testing.T) {
+	conn := newBatchConn()
+	b := newBatcher(BatchPolicy{Window: time.Hour}, func() Conn { return conn })
+	ctx := context.Background()
+
+	conn.setFail(true)
+	b.add(ctx, []byte("a"))
+	b.flushBackground()
+
+	// New conn clears background error, keeping the batch
+	conn.setFail(false)
+	b.reset()
+	if err := b.add(ctx, []byte("b")); err != nil {
+		t.Fatalf("expected no error adding after reset, got %v", err)
+	}
+	if err := b.flush(ctx); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+	if blocks, exp := conn.written(), [][]string{{"a", "b"}}; !reflect.DeepEqual(blocks, exp) {
+		t.Fatalf("written blocks were not as expected: {Expect=%q Written=%q}", exp, blocks)
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"sync"
+	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/gorilla/websocket"
 )
 
 // Sockjs client error messages
@@ -41,12 +44,83 @@ type Client struct {
 	// XHRDialer provides configuration for dialing XHR connections
 	XHRDialer *XHRDialer
 
-	// NoWebsocket indicates whether to prefer XHR connection over WS
+	// XHRStreamingDialer provides configuration for dialing XHR streaming connections
+	XHRStreamingDialer *XHRStreamingDialer
+
+	// EventSourceDialer provides configuration for dialing EventSource connections
+	EventSourceDialer *EventSourceDialer
+
+	// HTMLFileDialer provides configuration for dialing htmlfile connections
+	HTMLFileDialer *HTMLFileDialer
+
+	// JSONPDialer provides configuration for dialing JSONP polling connections
+	JSONPDialer *JSONPDialer
+
+	// Transports lists the transports to attempt in order of preference,
+	// falling back to the next on failure. If nil, DefaultTransports is used
+	Transports []Transport
+
+	// WhitelistTransports restricts the attempted transports to only those
+	// listed, as with the sockjs-client "transports" option. Ignored if empty
+	WhitelistTransports []Transport
+
+	// NoWebsocket indicates whether to prefer XHR connection over WS,
+	// equivalent to leaving TransportWebsocket out of Transports
 	NoWebsocket bool
 
-	conn Conn        // underlying client connection
-	info *ServerInfo // currently connected server info
-	mu   sync.Mutex  // protects conn
+	// HeartbeatTimeout is the time allowed without receiving any frame (including
+	// heartbeats) before the connection fails with ErrNoHeartbeat, for transports
+	// whose dialer does not set its own. Zero means DefaultHeartbeatTimeout, negative disables
+	HeartbeatTimeout time.Duration
+
+	// InboundBuffer is the number of received messages queued for reading, for
+	// transports whose dialer does not set its own. Zero means DefaultInboundBuffer
+	InboundBuffer int
+
+	// Overflow determines handling of messages received while the inbound buffer
+	// is full, for transports whose dialer does not set its own. OverflowDefault
+	// (the zero value) means OverflowBlock, where a slow reader stalls the connection
+	Overflow OverflowPolicy
+
+	// Jar is the cookie jar shared by the info request, websocket handshake and
+	// every XHR request, so sticky sessions (e.g. JSESSIONID, as indicated by
+	// ServerInfo.CookieNeeded) land on the same backend. If nil, one is created
+	Jar http.CookieJar
+
+	// OnEvent is called with each connection lifecycle event, e.g. transport
+	// fallback, heartbeats and reconnects. It may be called concurrently from
+	// the connection's own goroutines, so must be safe for this and not block.
+	// Hooks added by AddEventHook are called after it, whatever it is set to
+	OnEvent func(Event)
+
+	// Batch enables coalescing of messages written within a short window into
+	// single sockjs frames, sent on Flush() / Close() at the latest. Messages a write
+	// fails to send stay queued, retried after the window (across reconnects), the error
+	// returned from the next write or Flush(), until discarded by Close(). If nil, writes
+	// are sent immediately
+	Batch *BatchPolicy
+
+	// Codec encodes values passed to WriteValue and decodes those read by
+	// ReadValue. If nil, JSONCodec is used
+	Codec Codec
+
+	// Reconnect enables automatic reconnection using the given policy, should
+	// the connection be lost while reading. If nil, no reconnection is attempted
+	Reconnect *ReconnectPolicy
+
+	conn  Conn            // underlying client connection
+	trans Transport       // currently connected transport
+	info  *ServerInfo     // currently connected server info
+	bat   *batcher        // outbound message batcher, if enabled
+	pmp   *pump           // message delivery pump, if started
+	onMsg func([]byte)    // message delivery callback, if set
+	life  context.Context // connected lifetime context, cancelled on close
+	cncl  func()          // connected lifetime context cancel
+	lerr  error           // error ending the last connected lifetime, set before cancelled
+	hooks []func(Event)   // event hooks added by AddEventHook, only appended to
+	mu    sync.Mutex      // protects conn, trans, info, life, lerr, bat, pmp, onMsg
+	emu   sync.Mutex      // protects hooks
+	rmu   sync.Mutex      // serializes reconnects
 }
 
 func (c *Client) Connect() error {
@@ -54,26 +128,314 @@ func (c *Client) Connect() error {
 }
 
 func (c *Client) ConnectContext(ctx context.Context) error {
+	// Attempt to connect to server
+	conn, transport, info, err := c.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// On success, set with new lifetime
+	c.mu.Lock()
+	c.end(ErrClosedConnection) // any previous lifetime
+	c.conn = conn
+	c.trans = transport
+	c.info = info
+	c.pmp = nil
+	if c.bat != nil {
+		c.bat.reset()
+	}
+	c.life, c.cncl = context.WithCancel(context.Background())
+	c.mu.Unlock()
+
+	return nil
+}
+
+// connect fetches server info and attempts each allowed transport in turn, returning first successful conn
+func (c *Client) connect(ctx context.Context) (Conn, Transport, *ServerInfo, error) {
 	// First check we can connect to info endpoint
-	info, url, err := GetServerInfo(c.Address)
+	info, url, err := GetServerInfoContext(ctx, c.httpClient(), c.Address, c.Header)
 	if err != nil {
 		if c.Address == "" {
-			return errNoAddressProvided
+			return nil, "", nil, errNoAddressProvided
 		}
-		return fmt.Errorf("%w: connecting to info endpoint: %v", ErrClientCannotConnect, err)
+		return nil, "", nil, &ConnectError{Info: err}
 	}
 
 	// Check if server + session ID need generating
+	c.mu.Lock()
 	if c.ServerID == "" {
 		c.ServerID = paddedRandomIntn(999)
 	}
 	if c.SessionID == "" {
 		c.SessionID = uuid.Must(uuid.NewV4()).String()
 	}
+	serverID, sessionID := c.ServerID, c.SessionID
+	c.mu.Unlock()
+
+	// Attempt each transport in order of preference
+	cerr := &ConnectError{}
+	transports := c.transports(info)
+	for i, transport := range transports {
+		c.emit(Event{Type: EventConnecting, Transport: transport})
+		conn, err := c.dial(ctx, transport, url, serverID, sessionID)
+
+		// On success, return
+		if err == nil {
+			c.emit(Event{Type: EventOpen, Transport: transport})
+			return conn, transport, info, nil
+		}
+
+		// Add transport error for below
+		cerr.Attempts = append(cerr.Attempts, &TransportError{
+			Transport: transport,
+			Err:       err,
+		})
+		if i < len(transports)-1 {
+			c.emit(Event{Type: EventFallback, Transport: transport, Err: err})
+		}
+	}
+
+	return nil, "", nil, cerr
+}
+
+// reconnect attempts to replace the failed conn with a newly connected one under a new
+// session, according to the reconnect policy. The cause is returned if not reconnecting,
+// or ctx's error should it be done first (leaving reconnect to the next caller)
+func (c *Client) reconnect(ctx context.Context, failed Conn, cause error) error {
+	c.mu.Lock()
+	policy := c.Reconnect
+	life := c.life
+	c.mu.Unlock()
+
+	// Check reconnect is enabled and wanted
+	if policy == nil || life == nil || life.Err() != nil || !policy.retryable(cause) {
+		return cause
+	}
+
+	// Only perform one reconnect at a time
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	// Check for reconnect (or close) while waiting
+	if conn := c.Conn(); conn != failed {
+		if conn == nil {
+			return cause
+		}
+		return nil
+	}
+
+	// Ensure failed conn closed
+	failed.Close()
+
+	var err error
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		c.emit(Event{Type: EventReconnecting, Attempt: attempt, Err: cause})
+
+		// Wait out backoff delay
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-life.Done():
+			timer.Stop()
+			return cause
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+
+		// Attempt to connect under new session
+		c.mu.Lock()
+		c.SessionID = uuid.Must(uuid.NewV4()).String()
+		c.mu.Unlock()
+		var conn Conn
+		var transport Transport
+		var info *ServerInfo
+		dctx, cncl := joinContext(life, ctx)
+		conn, transport, info, err = c.connect(dctx)
+		cncl()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		// On success, set (unless closed meanwhile) and return
+		c.mu.Lock()
+		if life.Err() != nil {
+			c.mu.Unlock()
+			conn.Close()
+			return cause
+		}
+		c.conn = conn
+		c.trans = transport
+		c.info = info
+		if c.bat != nil {
+			c.bat.reset()
+		}
+		c.mu.Unlock()
+
+		c.emit(Event{Type: EventReconnected, Attempt: attempt})
+		return nil
+	}
+
+	// Out of attempts, mark disconnected
+	err = fmt.Errorf("%w: reconnecting after %v: %v", ErrClientCannotConnect, cause, err)
+	c.mu.Lock()
+	if c.life == life {
+		c.conn = nil
+		c.trans = ""
+		c.info = nil
+		c.end(err)
+	}
+	c.mu.Unlock()
+
+	return err
+}
+
+// lost ends the connected lifetime should conn, having failed with err, still be current
+func (c *Client) lost(conn Conn, err error) {
+	c.mu.Lock()
+	if c.conn == conn {
+		c.end(err)
+	}
+	c.mu.Unlock()
+}
+
+// end ends the connected lifetime (if any, and not already ended) with err,
+// must be called with mu held
+func (c *Client) end(err error) {
+	if c.life != nil && c.life.Err() == nil {
+		c.lerr = err
+		c.cncl()
+	}
+}
+
+// httpClient returns the HTTP client to use for info requests, i.e. that configured for XHR
+func (c *Client) httpClient() *http.Client {
+	var client *http.Client
+	if c.XHRDialer != nil {
+		client = c.XHRDialer.HTTPClient
+	}
+	return c.withJar(client)
+}
+
+// jar returns the client cookie jar, creating one if not set
+func (c *Client) jar() http.CookieJar {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Jar == nil {
+		c.Jar, _ = cookiejar.New(nil) // never errors
+	}
+	return c.Jar
+}
+
+// withJar returns a copy of HTTP client (or default if nil) using the client cookie jar, unless it has its own
+func (c *Client) withJar(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if client.Jar != nil {
+		return client
+	}
+	cp := *client
+	cp.Jar = c.jar()
+	return &cp
+}
+
+// wsDialerWithJar returns a copy of websocket dialer (or default if nil) using the client cookie jar, unless it has its own
+func (c *Client) wsDialerWithJar(dialer *websocket.Dialer) *websocket.Dialer {
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	if dialer.Jar != nil {
+		return dialer
+	}
+	cp := *dialer
+	cp.Jar = c.jar()
+	return &cp
+}
+
+// AddEventHook adds fn to be called with each connection lifecycle event after OnEvent,
+// as for OnEvent. Unlike OnEvent it cannot be replaced or removed, so is for use by
+// protocols layered over the client that must see events whatever OnEvent is set to
+func (c *Client) AddEventHook(fn func(Event)) {
+	c.emu.Lock()
+	c.hooks = append(c.hooks, fn)
+	c.emu.Unlock()
+}
+
+// emit passes a lifecycle event to the OnEvent hook, if set, then any added hooks
+func (c *Client) emit(ev Event) {
+	eventFunc(c.OnEvent).emit(ev)
+
+	c.emu.Lock()
+	hooks := c.hooks
+	c.emu.Unlock()
+	for _, fn := range hooks {
+		fn(ev)
+	}
+}
+
+// transportEvents returns an event hook for conns using transport
+func (c *Client) transportEvents(transport Transport) eventFunc {
+	return func(ev Event) {
+		ev.Transport = transport
+		c.emit(ev)
+	}
+}
+
+// transports returns the allowed transports to attempt in order of preference, given server info
+func (c *Client) transports(info *ServerInfo) []Transport {
+	transports := c.Transports
+	if transports == nil {
+		transports = DefaultTransports
+	}
+
+	allowed := make([]Transport, 0, len(transports))
+	for _, transport := range transports {
+		// Skip any not in a set whitelist
+		if len(c.WhitelistTransports) > 0 && !containsTransport(c.WhitelistTransports, transport) {
+			continue
+		}
+
+		// Skip websocket if disabled (or unavailable!)
+		if transport == TransportWebsocket && (c.NoWebsocket || !info.WebSocket) {
+			continue
+		}
+
+		allowed = append(allowed, transport)
+	}
 
-	// Websocket preferred (and available!)
-	var wsErr error
-	if !c.NoWebsocket && info.WebSocket {
+	return allowed
+}
+
+// connOptions returns opts with any left zero taking the Client's value, and
+// the Client's event hook for transport
+func (c *Client) connOptions(opts ConnOptions, transport Transport) ConnOptions {
+	opts.events = c.transportEvents(transport)
+	if opts.HeartbeatTimeout == 0 {
+		opts.HeartbeatTimeout = c.HeartbeatTimeout
+	}
+	if opts.InboundBuffer == 0 {
+		opts.InboundBuffer = c.InboundBuffer
+	}
+	if opts.Overflow == OverflowDefault {
+		opts.Overflow = c.Overflow
+	}
+	return opts
+}
+
+// httpDialer dials a sockjs conn over HTTP requests
+type httpDialer interface {
+	DialContext(ctx context.Context, addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error)
+}
+
+// dial attempts to dial a sockjs conn using transport at the given server URL, under server and session ID
+func (c *Client) dial(ctx context.Context, transport Transport, url *url.URL, serverID, sessionID string) (Conn, error) {
+	var dialer httpDialer
+	switch transport {
+	case TransportWebsocket:
 		// Take copy of URL
 		url := *url
 
@@ -86,66 +448,82 @@ func (c *Client) ConnectContext(ctx context.Context) error {
 		}
 
 		// Prepare WS dialer
-		dialer := c.WSDialer
-		if dialer == nil {
-			dialer = &WSDialer{}
+		wsd := WSDialer{}
+		if c.WSDialer != nil {
+			wsd = *c.WSDialer
 		}
+		wsd.Dialer = c.wsDialerWithJar(wsd.Dialer)
+		wsd.ConnOptions = c.connOptions(wsd.ConnOptions, transport)
 
 		// Attempt to dial websocket conn
-		wsConn, _, err := dialer.DialContext(
+		conn, _, err := wsd.DialContext(
 			ctx,
 			url.String(),
-			c.ServerID,
-			c.SessionID,
+			serverID,
+			sessionID,
 			c.Header,
 			c.Query,
 		)
+		return conn, err
 
-		// On success, set and return
-		if err == nil {
-			c.mu.Lock()
-			c.conn = wsConn
-			c.info = info
-			c.mu.Unlock()
-			return nil
+	case TransportXHRStreaming:
+		d := XHRStreamingDialer{}
+		if c.XHRStreamingDialer != nil {
+			d = *c.XHRStreamingDialer
 		}
+		d.HTTPClient = c.withJar(d.HTTPClient)
+		d.ConnOptions = c.connOptions(d.ConnOptions, transport)
+		dialer = &d
+
+	case TransportEventSource:
+		d := EventSourceDialer{}
+		if c.EventSourceDialer != nil {
+			d = *c.EventSourceDialer
+		}
+		d.HTTPClient = c.withJar(d.HTTPClient)
+		d.ConnOptions = c.connOptions(d.ConnOptions, transport)
+		dialer = &d
+
+	case TransportHTMLFile:
+		d := HTMLFileDialer{}
+		if c.HTMLFileDialer != nil {
+			d = *c.HTMLFileDialer
+		}
+		d.HTTPClient = c.withJar(d.HTTPClient)
+		d.ConnOptions = c.connOptions(d.ConnOptions, transport)
+		dialer = &d
+
+	case TransportXHRPolling:
+		d := XHRDialer{}
+		if c.XHRDialer != nil {
+			d = *c.XHRDialer
+		}
+		d.HTTPClient = c.withJar(d.HTTPClient)
+		d.ConnOptions = c.connOptions(d.ConnOptions, transport)
+		dialer = &d
+
+	case TransportJSONPPolling:
+		d := JSONPDialer{}
+		if c.JSONPDialer != nil {
+			d = *c.JSONPDialer
+		}
+		d.HTTPClient = c.withJar(d.HTTPClient)
+		d.ConnOptions = c.connOptions(d.ConnOptions, transport)
+		dialer = &d
 
-		// Set ws error for below
-		wsErr = err
-		log.Printf("websocket failed, using fallback: %v\n", err)
-	}
-
-	// Prepare XHR dialer
-	dialer := c.XHRDialer
-	if dialer == nil {
-		dialer = &XHRDialer{}
+	default:
+		return nil, fmt.Errorf("sockjsclient: unknown transport %q", transport)
 	}
 
-	// Attempt to dial XHR conn
-	xhrConn, _, xhrErr := dialer.DialContext(
+	// Attempt to dial HTTP transport conn
+	conn, _, err := dialer.DialContext(
 		ctx,
 		url.String(),
-		c.ServerID,
-		c.SessionID,
+		serverID,
+		sessionID,
 		c.Header,
 	)
-
-	// On success, set and return
-	if xhrErr == nil {
-		c.mu.Lock()
-		c.conn = xhrConn
-		c.info = info
-		c.mu.Unlock()
-		return nil
-	}
-
-	if wsErr != nil {
-		// Both websocket AND xhr connections failed
-		return fmt.Errorf("%w: connecting to ws, xhr endpoints: %v, %v", ErrClientCannotConnect, wsErr, xhrErr)
-	} else {
-		// Only xhr connection failed (was only one attempted)
-		return fmt.Errorf("%w: connecting to xhr endpoint: %v", ErrClientCannotConnect, xhrErr)
-	}
+	return conn, err
 }
 
 // Conn returns the underlying conn (nil if not connected)
@@ -156,12 +534,29 @@ func (c *Client) Conn() Conn {
 	return conn
 }
 
+// Transport returns the transport of the current conn (empty if not connected)
+func (c *Client) Transport() Transport {
+	c.mu.Lock()
+	transport := c.trans
+	c.mu.Unlock()
+	return transport
+}
+
 // IsWebsocket returns whether current connection is via websocket
 func (c *Client) IsWebsocket() bool {
 	_, ok := c.Conn().(*wsConn)
 	return ok
 }
 
+// Stats returns statistics for the current conn (empty if not connected)
+func (c *Client) Stats() Stats {
+	conn := c.Conn()
+	if conn == nil {
+		return Stats{}
+	}
+	return conn.Stats()
+}
+
 // ServerInfo returns ServerInfo related to current conn (empty if not connected)
 func (c *Client) ServerInfo() ServerInfo {
 	c.mu.Lock()
@@ -176,22 +571,84 @@ func (c *Client) ServerInfo() ServerInfo {
 	return info
 }
 
-// ReadMsg will read the next message from the sockjs connection
+// ReadMsg will read the next message from the sockjs connection, transparently
+// reconnecting should the connection be lost and a Reconnect policy be set
 func (c *Client) ReadMsg() ([]byte, error) {
-	conn := c.Conn()
-	if conn == nil {
-		return nil, ErrClientNotConnected
+	return c.ReadMsgContext(context.Background())
+}
+
+// ReadMsgContext is as ReadMsg, but gives up waiting (or reconnecting) with
+// ctx's error should it be done first. The connection stays open
+func (c *Client) ReadMsgContext(ctx context.Context) ([]byte, error) {
+	for {
+		conn := c.Conn()
+		if conn == nil {
+			return nil, ErrClientNotConnected
+		}
+
+		// Read next message from conn
+		b, err := conn.ReadMsgContext(ctx)
+		if err == nil {
+			return b, nil
+		} else if ctx.Err() != nil {
+			return nil, err
+		}
+
+		// Attempt to reconnect, else return (lost for good unless given up waiting)
+		if err := c.reconnect(ctx, conn, err); err != nil {
+			if ctx.Err() == nil {
+				c.lost(conn, err)
+			}
+			return nil, err
+		}
 	}
-	return conn.ReadMsg()
 }
 
 // WriteMsg will write a message to the sockjs connection
 func (c *Client) WriteMsg(msg []byte) error {
+	return c.WriteMsgContext(context.Background(), msg)
+}
+
+// WriteMsgContext is as WriteMsg, but abandons the write with ctx's error
+// should it be done first. The connection stays open
+func (c *Client) WriteMsgContext(ctx context.Context, msg []byte) error {
 	conn := c.Conn()
 	if conn == nil {
 		return ErrClientNotConnected
 	}
-	return conn.WriteMsg(msg)
+
+	// Queue message if batching
+	if bat := c.batcher(); bat != nil {
+		return bat.add(ctx, msg)
+	}
+
+	return conn.WriteMsgContext(ctx, msg)
+}
+
+// Flush will send any messages queued by a Batch policy immediately
+func (c *Client) Flush() error {
+	return c.FlushContext(context.Background())
+}
+
+// FlushContext is as Flush, but abandons the write with ctx's error should it be done first
+func (c *Client) FlushContext(ctx context.Context) error {
+	if bat := c.batcher(); bat != nil {
+		return bat.flush(ctx)
+	}
+	return nil
+}
+
+// batcher returns the outbound message batcher, nil if no Batch policy set
+func (c *Client) batcher() *batcher {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Batch == nil {
+		return nil
+	}
+	if c.bat == nil {
+		c.bat = newBatcher(*c.Batch, c.Conn)
+	}
+	return c.bat
 }
 
 // ReadJSON will read next message from the sockjs connection and attempt JSON decode into "v"
@@ -212,14 +669,51 @@ func (c *Client) WriteJSON(v interface{}) error {
 	return c.WriteMsg(b)
 }
 
-// Close will close an open sockjs connection
+// ReadValue will read next message from the sockjs connection and attempt decode into "v" using the client Codec
+func (c *Client) ReadValue(v interface{}) error {
+	b, err := c.ReadMsg()
+	if err != nil {
+		return err
+	}
+	return c.codec().Unmarshal(b, v)
+}
+
+// WriteValue will encode "v" using the client Codec and send to the sockjs connection
+func (c *Client) WriteValue(v interface{}) error {
+	b, err := c.codec().Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMsg(b)
+}
+
+// codec returns the client Codec, JSONCodec if not set
+func (c *Client) codec() Codec {
+	if c.Codec == nil {
+		return JSONCodec
+	}
+	return c.Codec
+}
+
+// Close will close an open sockjs connection, first draining any queued messages
 func (c *Client) Close() error {
+	// Drain batched messages
+	ferr := c.Flush()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.end(ErrClosedConnection) // stopping any reconnects
+	if c.bat != nil {
+		c.bat.discard() // any left unsent
+	}
 	if c.conn != nil {
 		err := c.conn.Close()
 		c.conn = nil
+		c.trans = ""
 		c.info = nil
+		if err == nil && !errors.Is(ferr, ErrClientNotConnected) {
+			err = ferr
+		}
 		return err
 	}
 	return nil
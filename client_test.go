@@ -2,96 +2,78 @@ package sockjsclient_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/igm/sockjs-go/v3/sockjs"
-	"github.com/third-light/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
 )
 
 func TestClientWebsocketSimple(t *testing.T) {
-	testClientSimple(t, true)
+	testClientSimple(t, sockjsclient.TransportWebsocket)
 }
 
 func TestClientXHRSimple(t *testing.T) {
-	testClientSimple(t, false)
+	testClientSimple(t, sockjsclient.TransportXHRPolling)
 }
 
-func testClientSimple(t *testing.T, useWebsocket bool) {
-	const addr = "127.0.0.1:8008"
-
-	msgCh := make(chan []byte)
-	rspCh := make(chan []byte)
-
-	// Start the sockjs test server
-	closeFunc := setupTestServer(t, addr, func(t *testing.T, session sockjs.Session) {
-		// Indicate a message being sent
-		msg := "hello world!"
-		msgCh <- []byte(msg)
-
-		// Send message to client
-		if err := session.Send(msg); err != nil {
-			t.Fatalf("error sending message to client: %v", err)
-		}
+func TestClientXHRStreamingSimple(t *testing.T) {
+	testClientSimple(t, sockjsclient.TransportXHRStreaming)
+}
 
-		// Now wait on expected response
-		expRsp := <-rspCh
+func TestClientEventSourceSimple(t *testing.T) {
+	testClientSimple(t, sockjsclient.TransportEventSource)
+}
 
-		// Attempt to receive rsp from client
-		rsp, err := session.Recv()
-		if err != nil {
-			t.Fatalf("error receiving message from client: %v", err)
-		}
+func TestClientHTMLFileSimple(t *testing.T) {
+	testClientSimple(t, sockjsclient.TransportHTMLFile)
+}
 
-		// Verify response is expected
-		if strings.TrimSpace(rsp) != string(expRsp) {
-			t.Fatalf("response from client was not as expected: {Expect=%q Response=%q}", string(expRsp), rsp)
-		}
+func TestClientJSONPSimple(t *testing.T) {
+	testClientSimple(t, sockjsclient.TransportJSONPPolling)
+}
 
-		// close both chans
-		close(rspCh)
-		close(msgCh)
-	}, useWebsocket)
-	defer closeFunc()
+func testClientSimple(t *testing.T, transport sockjsclient.Transport) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
 
-	// Create new client for srv addr
-	client := sockjsclient.Client{
-		Address: "http://" + addr + "/sockjs",
-	}
+	// Connect client and accept its session
+	client, session := connectTestClient(t, srv, transport)
 
-	// Attempt to connect
-	if err := client.Connect(); err != nil {
-		t.Fatalf("error connecting to sockjs test server: %v", err)
+	// Send message to client
+	msg := "hello world!"
+	if err := session.Send(msg); err != nil {
+		t.Fatalf("error sending message to client: %v", err)
 	}
 
-	// Wait on message being sent
-	expMsg := <-msgCh
-
 	// Receive this message
-	msg, err := client.ReadMsg()
+	rcv, err := client.ReadMsg()
 	if err != nil {
 		t.Fatalf("error receiving message from server: %v", err)
 	}
 
 	// Check message is expected
-	if !bytes.Equal(expMsg, bytes.TrimSpace(msg)) {
-		t.Fatalf("message from server was not as expected: {Expect=%q Message=%q}", string(expMsg), string(msg))
+	if !bytes.Equal([]byte(msg), rcv) {
+		t.Fatalf("message from server was not as expected: {Expect=%q Message=%q}", msg, string(rcv))
 	}
 
-	// Indicate a response being sent
-	rsp := []byte("is \"ack\" what i'm meant to say?")
-	rspCh <- rsp
-
-	// Send this to server
-	if err := client.WriteMsg(rsp); err != nil {
+	// Send a response to server
+	rsp := "is \"ack\" what i'm meant to say?"
+	if err := client.WriteMsg([]byte(rsp)); err != nil {
 		t.Fatalf("error sending message to server: %v", err)
 	}
 
-	// Wait for channel close
-	<-rspCh
-	<-msgCh
+	// Verify response is expected
+	got, err := session.Recv(testContext(t))
+	if err != nil {
+		t.Fatalf("error receiving message from client: %v", err)
+	} else if got != rsp {
+		t.Fatalf("response from client was not as expected: {Expect=%q Response=%q}", rsp, got)
+	}
 
 	// close connection
 	if client.Close() != nil {
@@ -99,42 +81,521 @@ func testClientSimple(t *testing.T, useWebsocket bool) {
 	}
 }
 
-// setupTestServer will attempt to setup a new sockjs server on addr with handler func
-func setupTestServer(t *testing.T, addr string, h func(*testing.T, sockjs.Session), useWebsocket bool) (closeFunc func()) {
-	srv := http.Server{Addr: addr}
-	closeFunc = func() { srv.Close() }
+func TestClientRemoteClose(t *testing.T) {
+	for _, transport := range []sockjsclient.Transport{
+		sockjsclient.TransportWebsocket,
+		sockjsclient.TransportXHRPolling,
+		sockjsclient.TransportXHRStreaming,
+		sockjsclient.TransportEventSource,
+		sockjsclient.TransportHTMLFile,
+		sockjsclient.TransportJSONPPolling,
+	} {
+		t.Run(string(transport), func(t *testing.T) {
+			srv := sockjstest.NewServer()
+			defer srv.Close()
+			client, session := connectTestClient(t, srv, transport)
+			defer client.Close()
+
+			// Heartbeats are skipped, messages before close still delivered
+			session.Heartbeat()
+			session.Send("last")
+			session.Close(sockjsclient.CloseGoAway, "Go away!")
+
+			if msg, err := client.ReadMsg(); err != nil || string(msg) != "last" {
+				t.Fatalf("expected last message before close, got %q (err=%v)", msg, err)
+			}
+			if _, err := client.ReadMsg(); !sockjsclient.IsGoAway(err) {
+				t.Fatalf("expected go away close error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClientStreamRotate(t *testing.T) {
+	for _, transport := range []sockjsclient.Transport{
+		sockjsclient.TransportXHRStreaming,
+		sockjsclient.TransportEventSource,
+		sockjsclient.TransportHTMLFile,
+	} {
+		t.Run(string(transport), func(t *testing.T) {
+			srv := sockjstest.NewServer()
+			defer srv.Close()
+			client, session := connectTestClient(t, srv, transport)
+			defer client.Close()
 
-	// Set the sockjs server handler
-	opts := sockjs.DefaultOptions
-	opts.Websocket = useWebsocket
-	srv.Handler = sockjs.NewHandler("/sockjs", opts, func(s sockjs.Session) {
-		h(t, s)
-	})
+			// Stream is reopened once rotated by the server
+			session.Send("a")
+			session.Rotate()
+			session.Send("b")
+			for _, exp := range []string{"a", "b"} {
+				if msg, err := client.ReadMsgContext(testContext(t)); err != nil || string(msg) != exp {
+					t.Fatalf("expected message %q across rotation, got %q (err=%v)", exp, msg, err)
+				}
+			}
 
-	// Start HTTP server in goroutine and
-	// catch any early (launch-related) errors
-	errs := make(chan error)
+			streams := 0
+			for _, req := range srv.Requests() {
+				if strings.HasSuffix(req.URL.Path, "/"+session.Transport) {
+					streams++
+				}
+			}
+			if streams != 2 {
+				t.Fatalf("expected stream opened twice, got %d", streams)
+			}
+		})
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	client := &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+		Reconnect:  &sockjsclient.ReconnectPolicy{InitialDelay: time.Millisecond},
+	}
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
+	defer client.Close()
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+
+	// Reconnected under a new session once interrupted
+	session.Close(1002, "Connection interrupted")
+	read := make(chan string, 1)
 	go func() {
-		err := srv.ListenAndServe()
-		select {
-		case errs <- err:
-			// sent
-		default:
-			// already returned
+		msg, err := client.ReadMsgContext(testContext(t))
+		if err != nil {
+			t.Errorf("error receiving message after reconnect: %v", err)
 		}
-		close(errs)
+		read <- string(msg)
 	}()
+	resumed, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting reconnected session: %v", err)
+	} else if resumed.ID == session.ID {
+		t.Fatalf("expected new session on reconnect, got %s again", resumed.ID)
+	}
+	resumed.Send("again")
+	if msg := <-read; msg != "again" {
+		t.Fatalf("message from server was not as expected: {Expect=%q Message=%q}", "again", msg)
+	}
 
-	select {
-	// Errored out
-	case err := <-errs:
-		if err != nil {
-			t.Fatalf("error starting HTTP server: %v", err)
+	// Not reconnected once told to go away
+	resumed.Close(sockjsclient.CloseGoAway, "Go away!")
+	if _, err := client.ReadMsgContext(testContext(t)); !sockjsclient.IsGoAway(err) {
+		t.Fatalf("expected go away close error, got %v", err)
+	}
+}
+
+func TestClientReconnectMaxAttempts(t *testing.T) {
+	srv := sockjstest.NewServer()
+	client := &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+		Reconnect:  &sockjsclient.ReconnectPolicy{InitialDelay: time.Millisecond, MaxAttempts: 2},
+	}
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
+	defer client.Close()
+
+	// Server gone, each attempt failing
+	srv.Close()
+	if _, err := client.ReadMsgContext(testContext(t)); !errors.Is(err, sockjsclient.ErrClientCannotConnect) {
+		t.Fatalf("expected ErrClientCannotConnect once out of attempts, got %v", err)
+	}
+	<-client.Done()
+	if err := client.Err(); !errors.Is(err, sockjsclient.ErrClientCannotConnect) {
+		t.Fatalf("expected ErrClientCannotConnect ending connection, got %v", err)
+	}
+}
+
+func TestClientContextCancel(t *testing.T) {
+	for _, transport := range []sockjsclient.Transport{
+		sockjsclient.TransportWebsocket,
+		sockjsclient.TransportXHRPolling,
+		sockjsclient.TransportXHRStreaming,
+		sockjsclient.TransportEventSource,
+		sockjsclient.TransportHTMLFile,
+		sockjsclient.TransportJSONPPolling,
+	} {
+		t.Run(string(transport), func(t *testing.T) {
+			srv := sockjstest.NewServer()
+			defer srv.Close()
+			client, session := connectTestClient(t, srv, transport)
+			defer client.Close()
+
+			// Abandoned read and write leave the conn open
+			ctx, cncl := context.WithTimeout(context.Background(), time.Millisecond*20)
+			defer cncl()
+			if _, err := client.ReadMsgContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected deadline exceeded reading, got %v", err)
+			}
+			if err := client.WriteMsgContext(ctx, []byte("maybe")); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected deadline exceeded (or success) writing, got %v", err)
+			}
+
+			session.Send("still open")
+			if msg, err := client.ReadMsgContext(testContext(t)); err != nil || string(msg) != "still open" {
+				t.Fatalf("expected message read after cancel, got %q (err=%v)", msg, err)
+			}
+			if err := client.WriteMsgContext(testContext(t), []byte("still open")); err != nil {
+				t.Fatalf("error writing after cancel: %v", err)
+			}
+			for {
+				msg, err := session.Recv(testContext(t))
+				if err != nil {
+					t.Fatalf("error receiving message from client: %v", err)
+				} else if msg == "still open" {
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestClientStickySession(t *testing.T) {
+	for _, transport := range []sockjsclient.Transport{
+		sockjsclient.TransportWebsocket,
+		sockjsclient.TransportXHRPolling,
+		sockjsclient.TransportXHRStreaming,
+		sockjsclient.TransportJSONPPolling,
+	} {
+		t.Run(string(transport), func(t *testing.T) {
+			// Info endpoint sets sticky session cookie
+			srv := sockjstest.NewUnstartedServer()
+			srv.Info.CookieNeeded = true
+			srv.HTTP.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/info") {
+					http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "sticky", Path: "/"})
+				}
+				srv.ServeHTTP(w, r)
+			})
+			srv.Start()
+			defer srv.Close()
+
+			client, session := connectTestClient(t, srv, transport)
+			defer client.Close()
+			client.WriteMsg([]byte("hello"))
+			if _, err := session.Recv(testContext(t)); err != nil {
+				t.Fatalf("error receiving message from client: %v", err)
+			}
+
+			// Cookie sent with every session request
+			for _, req := range srv.Requests() {
+				if strings.HasSuffix(req.URL.Path, "/info") {
+					continue
+				}
+				if cookie := req.Header.Get("Cookie"); cookie != "JSESSIONID=sticky" {
+					t.Fatalf("expected sticky session cookie on %s, got %q", req.URL.Path, cookie)
+				}
+			}
+		})
+	}
+}
+
+func TestClientHeartbeatTimeout(t *testing.T) {
+	for _, transport := range []sockjsclient.Transport{
+		sockjsclient.TransportWebsocket,
+		sockjsclient.TransportXHRPolling,
+		sockjsclient.TransportXHRStreaming,
+		sockjsclient.TransportEventSource,
+		sockjsclient.TransportHTMLFile,
+		sockjsclient.TransportJSONPPolling,
+	} {
+		t.Run(string(transport), func(t *testing.T) {
+			for _, tc := range []struct {
+				heartbeats bool
+				err        error
+			}{
+				{heartbeats: true, err: context.DeadlineExceeded},
+				{heartbeats: false, err: sockjsclient.ErrNoHeartbeat},
+			} {
+				srv := sockjstest.NewServer()
+				defer srv.Close()
+
+				client := &sockjsclient.Client{
+					Address:          srv.URL,
+					Transports:       []sockjsclient.Transport{transport},
+					HeartbeatTimeout: time.Millisecond * 100,
+				}
+				if err := client.ConnectContext(testContext(t)); err != nil {
+					t.Fatalf("error connecting to sockjs test server: %v", err)
+				}
+				defer client.Close()
+				session, err := srv.Accept(testContext(t))
+				if err != nil {
+					t.Fatalf("error accepting session: %v", err)
+				}
+
+				// Kept alive only by heartbeats
+				if tc.heartbeats {
+					ticker := time.NewTicker(time.Millisecond * 20)
+					defer ticker.Stop()
+					go func() {
+						for range ticker.C {
+							if session.Heartbeat() != nil {
+								return
+							}
+						}
+					}()
+				}
+				ctx, cncl := context.WithTimeout(context.Background(), time.Millisecond*300)
+				defer cncl()
+				if _, err := client.ReadMsgContext(ctx); !errors.Is(err, tc.err) {
+					t.Fatalf("expected %v with heartbeats=%v, got %v", tc.err, tc.heartbeats, err)
+				}
+			}
+		})
+	}
+}
+
+func TestClientInvalidFrame(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	client, session := connectTestClient(t, srv, sockjsclient.TransportWebsocket)
+	defer client.Close()
+
+	session.SendFrame("x[\"what\"]")
+	if _, err := client.ReadMsg(); !errors.Is(err, sockjsclient.ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse, got %v", err)
+	}
+}
+
+func TestClientInvalidOpen(t *testing.T) {
+	srv := sockjstest.NewUnstartedServer()
+	srv.Open = "x"
+	srv.Start()
+	defer srv.Close()
+
+	client := sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportXHRStreaming},
+	}
+	if err := client.ConnectContext(testContext(t)); !errors.Is(err, sockjsclient.ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse connecting, got %v", err)
+	}
+}
+
+func TestClientFaultsRetried(t *testing.T) {
+	for _, tc := range []struct {
+		fault  sockjstest.Fault
+		faults *sockjstest.Faults
+	}{
+		{fault: sockjstest.FaultBadGateway, faults: &sockjstest.Faults{Seed: 1, BadGateway: 0.5}},
+		{fault: sockjstest.FaultDropPoll, faults: &sockjstest.Faults{Seed: 1, DropPoll: 0.5}},
+		{fault: sockjstest.FaultLatency, faults: &sockjstest.Faults{Seed: 1, Latency: time.Millisecond, Jitter: time.Millisecond * 5}},
+	} {
+		t.Run(string(tc.fault), func(t *testing.T) {
+			srv := sockjstest.NewFaultyServer(tc.faults)
+			defer srv.Close()
+
+			client := &sockjsclient.Client{
+				Address:    srv.URL,
+				Transports: []sockjsclient.Transport{sockjsclient.TransportXHRPolling},
+				XHRDialer: &sockjsclient.XHRDialer{
+					RetryWindow: time.Second * 5,
+					RetryDelay:  time.Millisecond,
+				},
+			}
+			if err := client.ConnectContext(testContext(t)); err != nil {
+				t.Fatalf("error connecting to sockjs test server: %v", err)
+			}
+			defer client.Close()
+			session, err := srv.Accept(testContext(t))
+			if err != nil {
+				t.Fatalf("error accepting session: %v", err)
+			}
+
+			// All messages arrive in order despite faults
+			for i := 0; i < 10; i++ {
+				session.Send(string(rune('a' + i)))
+			}
+			for i := 0; i < 10; i++ {
+				msg, err := client.ReadMsgContext(testContext(t))
+				if err != nil {
+					t.Fatalf("error receiving message %d: %v", i, err)
+				} else if exp := string(rune('a' + i)); string(msg) != exp {
+					t.Fatalf("message from server was not as expected: {Expect=%q Message=%q}", exp, msg)
+				}
+			}
+
+			if tc.faults.Injected(tc.fault) == 0 {
+				t.Fatalf("expected %s faults to be injected", tc.fault)
+			}
+		})
+	}
+}
+
+func TestClientFaultNotFound(t *testing.T) {
+	srv := sockjstest.NewFaultyServer(&sockjstest.Faults{NotFound: 1})
+	defer srv.Close()
+	client, _ := connectTestClient(t, srv, sockjsclient.TransportXHRPolling)
+	defer client.Close()
+
+	if _, err := client.ReadMsgContext(testContext(t)); !sockjsclient.IsNotConnected(err) {
+		t.Fatalf("expected not connected error, got %v", err)
+	}
+}
+
+func TestClientFaultTruncate(t *testing.T) {
+	faults := &sockjstest.Faults{Truncate: 1}
+	srv := sockjstest.NewFaultyServer(faults)
+	defer srv.Close()
+	client, session := connectTestClient(t, srv, sockjsclient.TransportXHRStreaming)
+	defer client.Close()
+
+	session.Send("hello world!")
+	if _, err := client.ReadMsgContext(testContext(t)); !errors.Is(err, sockjsclient.ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse reading truncated frame, got %v", err)
+	} else if faults.Injected(sockjstest.FaultTruncate) != 1 {
+		t.Fatalf("expected one truncate fault, got %d", faults.Injected(sockjstest.FaultTruncate))
+	}
+}
+
+func TestClientFaultStallHeartbeats(t *testing.T) {
+	for _, transport := range []sockjsclient.Transport{
+		sockjsclient.TransportWebsocket,
+		sockjsclient.TransportXHRPolling,
+		sockjsclient.TransportXHRStreaming,
+	} {
+		t.Run(string(transport), func(t *testing.T) {
+			srv := sockjstest.NewFaultyServer(&sockjstest.Faults{StallHeartbeats: true})
+			defer srv.Close()
+
+			client := &sockjsclient.Client{
+				Address:          srv.URL,
+				Transports:       []sockjsclient.Transport{transport},
+				HeartbeatTimeout: time.Millisecond * 100,
+			}
+			if err := client.ConnectContext(testContext(t)); err != nil {
+				t.Fatalf("error connecting to sockjs test server: %v", err)
+			}
+			defer client.Close()
+			session, err := srv.Accept(testContext(t))
+			if err != nil {
+				t.Fatalf("error accepting session: %v", err)
+			}
+
+			// Heartbeat regularly, none of which arrive
+			go func() {
+				for session.Heartbeat() == nil {
+					time.Sleep(time.Millisecond * 20)
+				}
+			}()
+
+			if _, err := client.ReadMsgContext(testContext(t)); !errors.Is(err, sockjsclient.ErrNoHeartbeat) {
+				t.Fatalf("expected ErrNoHeartbeat, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClientFaultSeverWebsocket(t *testing.T) {
+	faults := &sockjstest.Faults{SeverWebsocket: 1}
+	srv := sockjstest.NewFaultyServer(faults)
+	defer srv.Close()
+	client, session := connectTestClient(t, srv, sockjsclient.TransportWebsocket)
+	defer client.Close()
+
+	session.Send("hello world!")
+	var cerr *sockjsclient.CloseError
+	if _, err := client.ReadMsgContext(testContext(t)); !errors.Is(err, sockjsclient.ErrClosedConnection) || errors.As(err, &cerr) {
+		t.Fatalf("expected ErrClosedConnection without close frame reading from severed websocket, got %v", err)
+	} else if faults.Injected(sockjstest.FaultSeverWebsocket) != 1 {
+		t.Fatalf("expected one sever fault, got %d", faults.Injected(sockjstest.FaultSeverWebsocket))
+	}
+}
+
+func TestClientEmptyFrame(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	client, session := connectTestClient(t, srv, sockjsclient.TransportWebsocket)
+	defer client.Close()
+
+	session.SendFrame("")
+	if _, err := client.ReadMsg(); !errors.Is(err, sockjsclient.ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse, got %v", err)
+	}
+}
+
+func TestClientBatch(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	client := &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportXHRPolling},
+		Batch:      &sockjsclient.BatchPolicy{Window: time.Hour},
+	}
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+
+	// Flush sends queued messages as one request
+	client.WriteMsg([]byte("a"))
+	client.WriteMsg([]byte("b"))
+	if err := client.Flush(); err != nil {
+		t.Fatalf("error flushing: %v", err)
+	}
+	sends := 0
+	for _, req := range srv.Requests() {
+		if strings.HasSuffix(req.URL.Path, "/xhr_send") {
+			sends++
+		}
+	}
+	if sends != 1 {
+		t.Fatalf("expected one send request flushing, got %d", sends)
+	}
+
+	// Close drains queued messages
+	client.WriteMsg([]byte("c"))
+	if err := client.Close(); err != nil {
+		t.Fatalf("error closing: %v", err)
+	}
+
+	for _, exp := range []string{"a", "b", "c"} {
+		if got, err := session.Recv(testContext(t)); err != nil {
+			t.Fatalf("error receiving message from client: %v", err)
+		} else if got != exp {
+			t.Fatalf("message from client was not as expected: {Expect=%q Message=%q}", exp, got)
 		}
+	}
+}
+
+// connectTestClient connects a new client to srv using transport, returning it and its server session
+func connectTestClient(t *testing.T, srv *sockjstest.Server, transport sockjsclient.Transport) (*sockjsclient.Client, *sockjstest.Session) {
+	client := &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{transport},
+	}
+
+	// Attempt to connect
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
 
-	// Handed off
-	case <-time.After(time.Second * 5):
+	// Accept the opened session
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
 	}
 
-	return
+	return client, session
+}
+
+// testContext returns a context bounding a single test step
+func testContext(t *testing.T) context.Context {
+	ctx, cncl := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cncl)
+	return ctx
 }
@@ -4,12 +4,13 @@ import (
 	"flag"
 	"fmt"
 
-	"github.com/third-light/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient"
 )
 
 func main() {
 	addr := flag.String("addr", "", "Sockjs server address")
 	ws := flag.Bool("ws", false, "Prefer websocket connection")
+	transports := flag.String("transports", "", "Comma separated transports to allow, in order of preference")
 	flag.Parse()
 
 	client := sockjsclient.Client{
@@ -17,6 +18,14 @@ func main() {
 		NoWebsocket: !*ws,
 	}
 
+	if *transports != "" {
+		var err error
+		client.Transports, err = sockjsclient.ParseTransports(*transports)
+		if err != nil {
+			panic(err)
+		}
+	}
+
 	err := client.Connect()
 	if err != nil {
 		panic(err)
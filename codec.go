@@ -0,0 +1,145 @@
+package sockjsclient
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Codec encodes values to and from sockjs message payloads, as used
+// by Client.ReadValue / Client.WriteValue
+type Codec interface {
+	// Marshal encodes v as a message payload
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes message payload data into v
+	Unmarshal(data []byte, v interface{}) error
+
+	// Name returns the content name of this codec, e.g. "json"
+	Name() string
+}
+
+// JSONCodec encodes values as JSON using encoding/json, the default Client codec
+var JSONCodec Codec = jsonCodec{}
+
+// jsonCodec implements Codec using encoding/json
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+// Base64Codec wraps a binary encoding (e.g. msgpack) in standard base64, so values
+// can be passed over sockjs text frames. For example, with a msgpack library:
+//
+//	codec := &Base64Codec{
+//		CodecName:     "msgpack",
+//		MarshalFunc:   msgpack.Marshal,
+//		UnmarshalFunc: msgpack.Unmarshal,
+//	}
+type Base64Codec struct {
+	// CodecName is the name of the wrapped encoding, reported
+	// by Name() with a "+base64" suffix
+	CodecName string
+
+	// MarshalFunc encodes v as binary data
+	MarshalFunc func(v interface{}) ([]byte, error)
+
+	// UnmarshalFunc decodes binary data into v
+	UnmarshalFunc func(data []byte, v interface{}) error
+}
+
+// Marshal implements Codec.Marshal()
+func (c *Base64Codec) Marshal(v interface{}) ([]byte, error) {
+	b, err := c.MarshalFunc(v)
+	if err != nil {
+		return nil, err
+	}
+	return encodeBase64(b), nil
+}
+
+// Unmarshal implements Codec.Unmarshal()
+func (c *Base64Codec) Unmarshal(data []byte, v interface{}) error {
+	b, err := decodeBase64(data)
+	if err != nil {
+		return err
+	}
+	return c.UnmarshalFunc(b, v)
+}
+
+// Name implements Codec.Name()
+func (c *Base64Codec) Name() string {
+	return c.CodecName + "+base64"
+}
+
+// ProtoBase64Codec encodes protobuf-style messages in standard base64, so they can
+// be passed over sockjs text frames. Values must implement Marshal() ([]byte, error)
+// to be written and Unmarshal([]byte) error to be read, as generated protobuf
+// messages commonly do, so no protobuf library is required here
+var ProtoBase64Codec Codec = protoBase64Codec{}
+
+// protoMarshaler is implemented by protobuf-style messages that can encode themselves
+type protoMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// protoUnmarshaler is implemented by protobuf-style messages that can decode themselves
+type protoUnmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+// protoBase64Codec implements Codec using self-encoding protobuf-style messages
+type protoBase64Codec struct{}
+
+func (protoBase64Codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(protoMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("sockjsclient: %T does not implement Marshal() ([]byte, error)", v)
+	}
+	b, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return encodeBase64(b), nil
+}
+
+func (protoBase64Codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(protoUnmarshaler)
+	if !ok {
+		return fmt.Errorf("sockjsclient: %T does not implement Unmarshal([]byte) error", v)
+	}
+	b, err := decodeBase64(data)
+	if err != nil {
+		return err
+	}
+	return m.Unmarshal(b)
+}
+
+func (protoBase64Codec) Name() string {
+	return "protobuf+base64"
+}
+
+// encodeBase64 returns b encoded in standard base64
+func encodeBase64(b []byte) []byte {
+	enc := make([]byte, base64.StdEncoding.EncodedLen(len(b)))
+	base64.StdEncoding.Encode(enc, b)
+	return enc
+}
+
+// decodeBase64 returns standard base64 data decoded
+func decodeBase64(data []byte) ([]byte, error) {
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(b, data)
+	if err != nil {
+		return nil, fmt.Errorf("sockjsclient: decoding base64: %w", err)
+	}
+	return b[:n], nil
+}
@@ -0,0 +1,101 @@
+package sockjsclient_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+// protoMsg is a protobuf-style message encoding itself
+type protoMsg struct {
+	data string
+}
+
+func (m *protoMsg) Marshal() ([]byte, error) {
+	return []byte(m.data), nil
+}
+
+func (m *protoMsg) Unmarshal(b []byte) error {
+	m.data = string(b)
+	return nil
+}
+
+func TestCodecs(t *testing.T) {
+	type value struct {
+		A string
+		B int
+	}
+	jsonBase64 := &sockjsclient.Base64Codec{
+		CodecName:     "json",
+		MarshalFunc:   json.Marshal,
+		UnmarshalFunc: json.Unmarshal,
+	}
+	for _, tc := range []struct {
+		codec    sockjsclient.Codec
+		name     string
+		in, out  interface{}
+		expected interface{}
+	}{
+		{codec: sockjsclient.JSONCodec, name: "json", in: value{A: "a", B: 1}, out: &value{}, expected: &value{A: "a", B: 1}},
+		{codec: jsonBase64, name: "json+base64", in: value{A: "b", B: 2}, out: &value{}, expected: &value{A: "b", B: 2}},
+		{codec: sockjsclient.ProtoBase64Codec, name: "protobuf+base64", in: &protoMsg{data: "\x00\xff"}, out: &protoMsg{}, expected: &protoMsg{data: "\x00\xff"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if name := tc.codec.Name(); name != tc.name {
+				t.Fatalf("codec name was not as expected: {Expect=%q Name=%q}", tc.name, name)
+			}
+			b, err := tc.codec.Marshal(tc.in)
+			if err != nil {
+				t.Fatalf("error marshalling: %v", err)
+			}
+			if err := tc.codec.Unmarshal(b, tc.out); err != nil {
+				t.Fatalf("error unmarshalling %q: %v", b, err)
+			} else if !reflect.DeepEqual(tc.out, tc.expected) {
+				t.Fatalf("round trip was not as expected: {Expect=%+v Got=%+v}", tc.expected, tc.out)
+			}
+		})
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	if _, err := sockjsclient.ProtoBase64Codec.Marshal("not proto"); err == nil {
+		t.Fatal("expected error marshalling non protobuf-style value")
+	} else if err := sockjsclient.ProtoBase64Codec.Unmarshal([]byte("AA=="), new(string)); err == nil {
+		t.Fatal("expected error unmarshalling into non protobuf-style value")
+	} else if err := sockjsclient.ProtoBase64Codec.Unmarshal([]byte("!!"), &protoMsg{}); err == nil {
+		t.Fatal("expected error unmarshalling invalid base64")
+	}
+}
+
+func TestClientCodec(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	client, session := connectTestClient(t, srv, sockjsclient.TransportWebsocket)
+	defer client.Close()
+	client.Codec = sockjsclient.ProtoBase64Codec
+
+	// Values written and read as base64 text messages
+	if err := client.WriteValue(&protoMsg{data: "out"}); err != nil {
+		t.Fatalf("error writing value: %v", err)
+	}
+	if msg, err := session.Recv(testContext(t)); err != nil || msg != base64.StdEncoding.EncodeToString([]byte("out")) {
+		t.Fatalf("expected base64 message from client, got %q (err=%v)", msg, err)
+	}
+
+	session.Send(base64.StdEncoding.EncodeToString([]byte("in")))
+	var m protoMsg
+	if err := client.ReadValue(&m); err != nil || m.data != "in" {
+		t.Fatalf("expected value read from base64 message, got %q (err=%v)", m.data, err)
+	}
+
+	// Undecodable message
+	session.Send("!!")
+	if err := client.ReadValue(&m); err == nil || errors.Is(err, sockjsclient.ErrClosedConnection) {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+}
@@ -2,9 +2,12 @@ package sockjsclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -16,6 +19,8 @@ var (
 	ErrInvalidResponse    = errors.New("sockjsclient: invalid server response")
 	ErrUnexpectedResponse = errors.New("sockjsclient: unexpected server response")
 	ErrNoHeartbeat        = errors.New("sockjsclient: no heartbeat")
+	ErrNoPong             = errors.New("sockjsclient: no pong")
+	ErrInboundOverflow    = errors.New("sockjsclient: inbound buffer overflow")
 )
 
 // MessageType represents a sockjs message type
@@ -35,9 +40,21 @@ type Conn interface {
 	// ReadMsg reads the next single data message from the sockjs connection
 	ReadMsg() ([]byte, error)
 
+	// ReadMsgContext reads the next single data message from the sockjs connection,
+	// giving up with ctx's error should it be done first. The connection stays open
+	ReadMsgContext(context.Context) ([]byte, error)
+
 	// WriteMsg writes a block of data messages to the sockjs connection
 	WriteMsg(...[]byte) error
 
+	// WriteMsgContext writes a block of data messages to the sockjs connection,
+	// abandoning the write with ctx's error should it be done first. The connection
+	// stays open, though an abandoned write may still have reached the server
+	WriteMsgContext(context.Context, ...[]byte) error
+
+	// Stats returns the current connection statistics
+	Stats() Stats
+
 	// Close will close the sockjs connection
 	Close() error
 
@@ -45,8 +62,49 @@ type Conn interface {
 	GetConnection() *websocket.Conn
 }
 
+// ConnOptions are the conn options common to all transports, embedded in each dialer.
+// Any left zero in a dialer used by a Client take the Client's value
+type ConnOptions struct {
+	// HeartbeatTimeout is the time allowed without receiving any frame before the
+	// conn fails with ErrNoHeartbeat. Zero means DefaultHeartbeatTimeout, negative disables
+	HeartbeatTimeout time.Duration
+
+	// InboundBuffer is the number of received messages queued for reading.
+	// Zero means DefaultInboundBuffer
+	InboundBuffer int
+
+	// Overflow determines handling of messages received while the inbound
+	// buffer is full. OverflowDefault (the zero value) means OverflowBlock, set
+	// OverflowBlock explicitly to block regardless of the Client's policy
+	Overflow OverflowPolicy
+
+	events eventFunc // lifecycle event hook, set by Client
+}
+
+// marshalMessages converts the given data messages to a sockjs message block
+func marshalMessages(data [][]byte) ([]byte, error) {
+	msgs := make([]string, 0, len(data))
+	for _, b := range data {
+		msgs = append(msgs, string(b))
+	}
+	return json.Marshal(msgs)
+}
+
+// unmarshalMessages parses the data messages contained in a sockjs message block
+func unmarshalMessages(b []byte) ([]string, error) {
+	msgs := []string{}
+	if err := json.Unmarshal(b, &msgs); err != nil {
+		return nil, fmt.Errorf("%w: decoding message block: %v", ErrInvalidResponse, err)
+	}
+	return msgs, nil
+}
+
 // parseMessage attempts to parse a valid sockjs message from given data
 func parseMessage(data []byte) (MessageType, []byte, error) {
+	if len(data) == 0 {
+		return MessageTypeUnhandled, nil, fmt.Errorf("%w: empty message", ErrInvalidResponse)
+	}
+
 	switch data[0] {
 	// Heartbeat
 	case 'h':
@@ -69,9 +127,8 @@ func parseMessage(data []byte) (MessageType, []byte, error) {
 
 	// Session closed
 	case 'c':
-		var v []interface{}
-		if err := json.Unmarshal(data[1:], &v); err == nil && len(v) == 2 {
-			return MessageTypeClose, nil, fmt.Errorf("%w (%v, %v)", ErrClosedByRemote, v[0], v[1])
+		if code, reason, ok := parseCloseFrame(data); ok {
+			return MessageTypeClose, nil, &CloseError{Code: code, Reason: reason}
 		}
 		return MessageTypeClose, nil, fmt.Errorf("%w (extra close data was missing/invalid)", ErrClosedByRemote)
 
@@ -80,3 +137,17 @@ func parseMessage(data []byte) (MessageType, []byte, error) {
 		return MessageTypeUnhandled, nil, fmt.Errorf("%w: unknown message type '%c'", ErrInvalidResponse, data[0])
 	}
 }
+
+// parseCloseFrame attempts to parse the [code, reason] pair from a sockjs close frame
+func parseCloseFrame(data []byte) (int, string, bool) {
+	var v []interface{}
+	if err := json.Unmarshal(data[1:], &v); err != nil || len(v) != 2 {
+		return 0, "", false
+	}
+	code, ok := v[0].(float64)
+	if !ok {
+		return 0, "", false
+	}
+	reason, _ := v[1].(string)
+	return int(code), reason, true
+}
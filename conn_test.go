@@ -0,0 +1,31 @@
+package sockjsclient
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		mt   MessageType
+		err  error
+	}{
+		{data: "o", mt: MessageTypeOpen},
+		{data: "h", mt: MessageTypeHeartbeat},
+		{data: `a["x"]`, mt: MessageTypeData},
+		{data: `c[3000,"Go away!"]`, mt: MessageTypeClose, err: ErrClosedByRemote},
+		{data: "x", mt: MessageTypeUnhandled, err: ErrInvalidResponse},
+		{data: "", mt: MessageTypeUnhandled, err: ErrInvalidResponse},
+	} {
+		mt, _, err := parseMessage([]byte(tc.data))
+		if mt != tc.mt || !errors.Is(err, tc.err) {
+			t.Fatalf("parsed message %q was not as expected: {Expect=%d,%v Got=%d,%v}", tc.data, tc.mt, tc.err, mt, err)
+		}
+	}
+
+	// Undecodable message blocks
+	if _, err := unmarshalMessages([]byte(`["x"`)); !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse unmarshalling truncated block, got %v", err)
+	}
+}
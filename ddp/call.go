@@ -0,0 +1,84 @@
+package ddp
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Call represents a method call, tracking receipt of its result and its updated message
+type Call struct {
+	ID     string
+	Method string
+	Params []interface{}
+
+	result     json.RawMessage // method result, set on done
+	err        error           // method error, set on done
+	hasRes     bool            // whether result received
+	hasUpdated bool            // whether updated received
+	done       chan struct{}   // closed on result
+	updated    chan struct{}   // closed on updated
+	mu         sync.Mutex      // protects result, err, hasRes, hasUpdated
+}
+
+// message returns the method message for this call
+func (call *Call) message() *message {
+	return &message{
+		Msg:    "method",
+		ID:     call.ID,
+		Method: call.Method,
+		Params: call.Params,
+	}
+}
+
+// setResult records the call result, returning whether the call is now complete
+func (call *Call) setResult(result json.RawMessage, err *Error) bool {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	if call.hasRes {
+		return call.hasUpdated
+	}
+	call.result = result
+	if err != nil {
+		call.err = err
+	}
+	call.hasRes = true
+	close(call.done)
+	return call.hasUpdated
+}
+
+// setUpdated records the call's writes as reflected, returning whether the call is now complete
+func (call *Call) setUpdated() bool {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	if !call.hasUpdated {
+		call.hasUpdated = true
+		close(call.updated)
+	}
+	return call.hasRes
+}
+
+// hasResult returns whether the call result has been received
+func (call *Call) hasResult() bool {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	return call.hasRes
+}
+
+// Done returns a channel that is closed once the call result is received
+func (call *Call) Done() <-chan struct{} {
+	return call.done
+}
+
+// Updated returns a channel that is closed once the server has sent all writes made
+// by the call to the collection caches, i.e. its updated message is received
+func (call *Call) Updated() <-chan struct{} {
+	return call.updated
+}
+
+// Result returns the call result, or error returned by the method as an *Error.
+// It is only valid once Done is closed
+func (call *Call) Result() (json.RawMessage, error) {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	return call.result, call.err
+}
@@ -0,0 +1,493 @@
+// Package ddp implements a Meteor DDP client over a sockjs Client
+package ddp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/internal/layer"
+)
+
+// DefaultVersions are the DDP protocol versions supported, in order of preference
+var DefaultVersions = []string{"1", "pre2", "pre1"}
+
+// DDP client error messages
+var (
+	ErrNotConnected        = errors.New("ddp: client not connected")
+	ErrUnsupportedVersion  = errors.New("ddp: no supported protocol version")
+	ErrUnexpectedMessage   = errors.New("ddp: unexpected message")
+	ErrSubscriptionStopped = errors.New("ddp: subscription stopped before ready")
+)
+
+// Client is a DDP client layered over a sockjs Client. Whenever the sockjs client reconnects
+// (see sockjsclient.Client.Reconnect) a new DDP session is opened, the collection cache cleared,
+// all subscriptions made again, and any method calls still awaiting a result sent again
+type Client struct {
+	// SockJS is the sockjs client carrying DDP messages, configured but not yet connected.
+	// Connect adds an event hook to it, to open a new DDP session on reconnect
+	SockJS *sockjsclient.Client
+
+	// Versions are the DDP protocol versions to support, in order of
+	// preference. Defaults to DefaultVersions
+	Versions []string
+
+	// OnError is called with any protocol error received from the server
+	OnError func(*ProtocolError)
+
+	nextID int64 // last generated id, accessed atomically
+
+	session     string                   // current session id
+	version     string                   // negotiated protocol version
+	calls       map[string]*Call         // pending method calls by id
+	subs        map[string]*Subscription // active subscriptions by id
+	pings       map[string]chan struct{} // pong waiters by ping id
+	collections map[string]*Collection   // collection caches by name
+	life        layer.Lifecycle          // read loop lifecycle
+	mu          sync.Mutex               // protects session, version, calls, subs, pings, collections
+}
+
+func (c *Client) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext connects the sockjs client and negotiates a DDP session, reconnecting with
+// the server's suggested version if necessary, giving up with ctx's error should it be done first
+func (c *Client) ConnectContext(ctx context.Context) error {
+	// Resume session on each reconnect
+	c.life.Hook(c.SockJS, func(ev sockjsclient.Event) {
+		if ev.Type == sockjsclient.EventReconnected {
+			go c.resume()
+		}
+	})
+
+	versions := c.versions()
+	version := versions[0]
+	tried := map[string]bool{}
+	for {
+		tried[version] = true
+
+		// Connect underlying sockjs client
+		if err := c.SockJS.ConnectContext(ctx); err != nil {
+			return err
+		}
+
+		// Negotiate session
+		msg, err := c.handshake(ctx, version)
+		if err != nil {
+			c.SockJS.Close()
+			return err
+		}
+		if msg.Msg == "connected" {
+			c.mu.Lock()
+			c.session = msg.Session
+			c.version = version
+			c.mu.Unlock()
+			break
+		}
+
+		// Server closes conn on failure, retry with its suggested version if supported
+		c.SockJS.Close()
+		if !containsString(versions, msg.Version) || tried[msg.Version] {
+			return fmt.Errorf("%w (server suggested %q)", ErrUnsupportedVersion, msg.Version)
+		}
+		version = msg.Version
+		c.SockJS.SessionID = "" // new sockjs session
+	}
+
+	// Set up new session
+	c.mu.Lock()
+	c.calls = map[string]*Call{}
+	c.subs = map[string]*Subscription{}
+	c.pings = map[string]chan struct{}{}
+	if c.collections == nil {
+		c.collections = map[string]*Collection{}
+	}
+	for _, coll := range c.collections {
+		coll.clear()
+	}
+	run := c.life.Start()
+	c.mu.Unlock()
+
+	go c.readLoop(run)
+
+	return nil
+}
+
+// versions returns the supported protocol versions
+func (c *Client) versions() []string {
+	if len(c.Versions) == 0 {
+		return DefaultVersions
+	}
+	return c.Versions
+}
+
+// connectMessage returns the connect message proposing version
+func (c *Client) connectMessage(version string) *message {
+	return &message{
+		Msg:     "connect",
+		Version: version,
+		Support: c.versions(),
+	}
+}
+
+// handshake sends connect proposing version, then reads until a connected or failed message
+func (c *Client) handshake(ctx context.Context, version string) (*message, error) {
+	if err := c.write(ctx, c.connectMessage(version)); err != nil {
+		return nil, err
+	}
+
+	for {
+		b, err := c.SockJS.ReadMsgContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		msg := &message{}
+		if err := json.Unmarshal(b, msg); err != nil {
+			return nil, err
+		}
+
+		switch msg.Msg {
+		// Negotiation complete
+		case "connected", "failed":
+			return msg, nil
+
+		// Keep alive while negotiating
+		case "ping":
+			if err := c.write(ctx, &message{Msg: "pong", ID: msg.ID}); err != nil {
+				return nil, err
+			}
+
+		// Skip server_id announcement (no msg field)
+		case "":
+
+		default:
+			return nil, fmt.Errorf("%w: %q awaiting connected", ErrUnexpectedMessage, msg.Msg)
+		}
+	}
+}
+
+// resume sends connect over a newly reconnected sockjs conn. State is then
+// resumed by the read loop on receiving connected
+func (c *Client) resume() {
+	c.mu.Lock()
+	version := c.version
+	c.mu.Unlock()
+	c.write(context.Background(), c.connectMessage(version)) // failure is seen by read loop
+}
+
+// resumed clears the collection caches, then makes all subscriptions and
+// pending method calls again over a newly opened session
+func (c *Client) resumed(session string) {
+	c.mu.Lock()
+	c.session = session
+	for _, coll := range c.collections {
+		coll.clear()
+	}
+	var msgs []*message
+	for _, sub := range c.subs {
+		msgs = append(msgs, sub.message())
+	}
+	for _, call := range c.calls {
+		if !call.hasResult() {
+			msgs = append(msgs, call.message())
+		}
+	}
+	c.mu.Unlock()
+
+	for _, msg := range msgs {
+		c.write(context.Background(), msg) // failure is seen by read loop
+	}
+}
+
+// readLoop reads and dispatches messages until the sockjs client fails or is closed
+func (c *Client) readLoop(run *layer.Run) {
+	run.Stop(c.dispatch())
+}
+
+// dispatch reads messages, applying each, until an error occurs
+func (c *Client) dispatch() error {
+	for {
+		b, err := c.SockJS.ReadMsg()
+		if err != nil {
+			return err
+		}
+		msg := &message{}
+		if err := json.Unmarshal(b, msg); err != nil {
+			return err
+		}
+
+		switch msg.Msg {
+		// Keep alive
+		case "ping":
+			c.write(context.Background(), &message{Msg: "pong", ID: msg.ID}) // failure is seen by read loop
+		case "pong":
+			c.mu.Lock()
+			ch := c.pings[msg.ID]
+			delete(c.pings, msg.ID)
+			c.mu.Unlock()
+			if ch != nil {
+				close(ch)
+			}
+
+		// Session (re)opened after reconnect
+		case "connected":
+			c.resumed(msg.Session)
+		case "failed":
+			return fmt.Errorf("%w (server suggested %q)", ErrUnsupportedVersion, msg.Version)
+
+		// Method call progress
+		case "result":
+			c.mu.Lock()
+			call := c.calls[msg.ID]
+			if call != nil && call.setResult(msg.Result, msg.Error) {
+				delete(c.calls, msg.ID)
+			}
+			c.mu.Unlock()
+		case "updated":
+			c.mu.Lock()
+			for _, id := range msg.Methods {
+				call := c.calls[id]
+				if call != nil && call.setUpdated() {
+					delete(c.calls, id)
+				}
+			}
+			c.mu.Unlock()
+
+		// Subscription progress
+		case "ready":
+			c.mu.Lock()
+			for _, id := range msg.Subs {
+				if sub := c.subs[id]; sub != nil {
+					sub.setReady()
+				}
+			}
+			c.mu.Unlock()
+		case "nosub":
+			c.mu.Lock()
+			sub := c.subs[msg.ID]
+			delete(c.subs, msg.ID)
+			c.mu.Unlock()
+			if sub != nil {
+				sub.stop(msg.Error)
+			}
+
+		// Collection data
+		case "added", "addedBefore", "changed", "removed":
+			c.Collection(msg.Collection).apply(msg)
+
+		// Server could not process a message
+		case "error":
+			if c.OnError != nil {
+				c.OnError(&ProtocolError{Reason: msg.Reason, OffendingMessage: msg.OffendingMessage})
+			}
+		}
+	}
+}
+
+// write sends a message to the server
+func (c *Client) write(ctx context.Context, msg *message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.SockJS.WriteMsgContext(ctx, b)
+}
+
+// newID returns a new id unique to this client
+func (c *Client) newID() string {
+	return strconv.FormatInt(atomic.AddInt64(&c.nextID, 1), 10)
+}
+
+// Session returns the current DDP session id
+func (c *Client) Session() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+// Version returns the negotiated DDP protocol version
+func (c *Client) Version() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// Collection returns the local cache of the named collection, which is
+// populated by subscriptions. It is created empty if not yet known
+func (c *Client) Collection(name string) *Collection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.collections == nil {
+		c.collections = map[string]*Collection{}
+	}
+	coll, ok := c.collections[name]
+	if !ok {
+		coll = newCollection(name)
+		c.collections[name] = coll
+	}
+	return coll
+}
+
+// Go starts calling method with params, returning the pending call to track its progress
+func (c *Client) Go(method string, params ...interface{}) (*Call, error) {
+	call := &Call{
+		ID:      c.newID(),
+		Method:  method,
+		Params:  params,
+		done:    make(chan struct{}),
+		updated: make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	if c.calls == nil {
+		c.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	c.calls[call.ID] = call
+	c.mu.Unlock()
+
+	if err := c.write(context.Background(), call.message()); err != nil {
+		c.mu.Lock()
+		delete(c.calls, call.ID)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	return call, nil
+}
+
+// Call calls method with params, waiting until both its result is received and its writes
+// are reflected in the collection caches (the updated message), or ctx or the client is done
+func (c *Client) Call(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
+	call, err := c.Go(method, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	done := c.Done()
+	for _, ch := range []<-chan struct{}{call.Done(), call.Updated()} {
+		select {
+		case <-ch:
+		case <-done:
+			return nil, c.Err()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return call.Result()
+}
+
+// Subscribe subscribes to the named record set with params, waiting until it is ready. The
+// collection caches are then populated. Should ctx or the client be done first, the
+// subscription is stopped and the error returned. A nosub is returned as an *Error, or
+// as ErrSubscriptionStopped should it carry no error
+func (c *Client) Subscribe(ctx context.Context, name string, params ...interface{}) (*Subscription, error) {
+	sub := &Subscription{
+		ID:     c.newID(),
+		Name:   name,
+		Params: params,
+		client: c,
+		ready:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	if c.subs == nil {
+		c.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	c.subs[sub.ID] = sub
+	done := c.life.Done()
+	c.mu.Unlock()
+
+	if err := c.write(ctx, sub.message()); err != nil {
+		c.mu.Lock()
+		delete(c.subs, sub.ID)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case <-sub.Ready():
+		select {
+		case <-sub.Done():
+			if err := sub.Err(); err != nil {
+				return nil, err
+			}
+			return nil, ErrSubscriptionStopped
+		default:
+			return sub, nil
+		}
+	case <-done:
+		return nil, c.Err()
+	case <-ctx.Done():
+		sub.Unsubscribe() // best effort
+		return nil, ctx.Err()
+	}
+}
+
+// Ping sends a ping, waiting until the server's pong is received, or ctx or the client is done
+func (c *Client) Ping(ctx context.Context) error {
+	id := c.newID()
+	ch := make(chan struct{})
+	c.mu.Lock()
+	if c.pings == nil {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	c.pings[id] = ch
+	done := c.life.Done()
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pings, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(ctx, &message{Msg: "ping", ID: id}); err != nil {
+		return err
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case <-done:
+		return c.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel that is closed once the client stops reading
+// messages, i.e. the sockjs client was closed or lost for good
+func (c *Client) Done() <-chan struct{} {
+	return c.life.Done()
+}
+
+// Err returns the error that stopped the client reading messages, nil until Done is
+// closed, or ErrNotConnected if never connected
+func (c *Client) Err() error {
+	return c.life.Err(ErrNotConnected)
+}
+
+// Close closes the sockjs client
+func (c *Client) Close() error {
+	return c.SockJS.Close()
+}
+
+// containsString returns whether s is within strs
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
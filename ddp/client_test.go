@@ -0,0 +1,319 @@
+package ddp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestClientConnect(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	c := newTestClient(srv)
+	connected := make(chan error, 1)
+	go func() { connected <- c.ConnectContext(testContext(t)) }()
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+	defer c.Close()
+
+	// Connect proposes preferred version, supporting all
+	msg := recvTestMessage(t, session)
+	if msg.Msg != "connect" || msg.Version != "1" || len(msg.Support) != len(DefaultVersions) {
+		t.Fatalf("connect message was not as expected: %+v", msg)
+	}
+
+	// Server id skipped and pings answered while negotiating
+	session.Send(`{"server_id":"0"}`)
+	sendTestMessage(t, session, &message{Msg: "ping", ID: "p1"})
+	if msg := recvTestMessage(t, session); msg.Msg != "pong" || msg.ID != "p1" {
+		t.Fatalf("pong message was not as expected: %+v", msg)
+	}
+
+	sendTestMessage(t, session, &message{Msg: "connected", Session: "s1"})
+	if err := <-connected; err != nil {
+		t.Fatalf("error connecting ddp client: %v", err)
+	} else if c.Session() != "s1" || c.Version() != "1" {
+		t.Fatalf("negotiated session was not as expected: {Session=%s Version=%s}", c.Session(), c.Version())
+	}
+}
+
+func TestClientConnectFailed(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	c := newTestClient(srv)
+	connected := make(chan error, 1)
+	go func() { connected <- c.ConnectContext(testContext(t)) }()
+	defer c.Close()
+
+	// Reconnected (under a new sockjs session) with the suggested version
+	first, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+	recvTestMessage(t, first)
+	sendTestMessage(t, first, &message{Msg: "failed", Version: "pre1"})
+
+	second, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting retried session: %v", err)
+	} else if second.ID == first.ID {
+		t.Fatalf("expected new sockjs session on retry, got %s again", second.ID)
+	}
+	if msg := recvTestMessage(t, second); msg.Msg != "connect" || msg.Version != "pre1" {
+		t.Fatalf("retried connect message was not as expected: %+v", msg)
+	}
+
+	// Failed again, with the version already tried
+	sendTestMessage(t, second, &message{Msg: "failed", Version: "pre1"})
+	if err := <-connected; !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestClientCall(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	c, session := connectTestClient(t, srv)
+	defer c.Close()
+
+	type result struct {
+		res json.RawMessage
+		err error
+	}
+	results := make(chan result, 1)
+	go func() {
+		res, err := c.Call(testContext(t), "tasks.add", "one")
+		results <- result{res, err}
+	}()
+	msg := recvTestMessage(t, session)
+	if msg.Msg != "method" || msg.Method != "tasks.add" || len(msg.Params) != 1 {
+		t.Fatalf("method message was not as expected: %+v", msg)
+	}
+
+	// Not complete until both result and updated received
+	sendTestMessage(t, session, &message{Msg: "result", ID: msg.ID, Result: json.RawMessage(`"a"`)})
+	sendTestMessage(t, session, &message{Msg: "added", Collection: "tasks", ID: "a", Fields: map[string]json.RawMessage{"text": json.RawMessage(`"one"`)}})
+	select {
+	case res := <-results:
+		t.Fatalf("expected call to await updated, got %+v", res)
+	case <-time.After(time.Millisecond * 20):
+	}
+	sendTestMessage(t, session, &message{Msg: "updated", Methods: []string{msg.ID}})
+
+	if res := <-results; res.err != nil || string(res.res) != `"a"` {
+		t.Fatalf("call result was not as expected: %+v", res)
+	} else if _, ok := c.Collection("tasks").Get("a"); !ok {
+		t.Fatal("expected method writes cached once updated")
+	}
+
+	// Method errors returned as *Error
+	call, err := c.Go("tasks.remove", "b")
+	if err != nil {
+		t.Fatalf("error calling method: %v", err)
+	}
+	msg = recvTestMessage(t, session)
+	sendTestMessage(t, session, &message{Msg: "result", ID: msg.ID, Error: &Error{Code: 404, Reason: "not found"}})
+	<-call.Done()
+	var derr *Error
+	if _, err := call.Result(); !errors.As(err, &derr) || derr.Reason != "not found" {
+		t.Fatalf("expected method error, got %v", err)
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	c, session := connectTestClient(t, srv)
+	defer c.Close()
+
+	subs := make(chan *Subscription, 1)
+	go func() {
+		sub, err := c.Subscribe(testContext(t), "tasks", "mine")
+		if err != nil {
+			t.Errorf("error subscribing: %v", err)
+		}
+		subs <- sub
+	}()
+	msg := recvTestMessage(t, session)
+	if msg.Msg != "sub" || msg.Name != "tasks" {
+		t.Fatalf("sub message was not as expected: %+v", msg)
+	}
+
+	// Ready once initial records sent
+	sendTestMessage(t, session, &message{Msg: "added", Collection: "tasks", ID: "a"})
+	sendTestMessage(t, session, &message{Msg: "ready", Subs: []string{msg.ID}})
+	sub := <-subs
+	if sub == nil {
+		t.Fatal("expected subscription once ready")
+	} else if n := c.Collection("tasks").Len(); n != 1 {
+		t.Fatalf("expected 1 cached record once ready, got %d", n)
+	}
+
+	// Stopped by server with nosub
+	sendTestMessage(t, session, &message{Msg: "nosub", ID: msg.ID, Error: &Error{Code: "gone", Reason: "removed"}})
+	select {
+	case <-sub.Done():
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for nosub")
+	}
+	var derr *Error
+	if err := sub.Err(); !errors.As(err, &derr) || derr.Reason != "removed" {
+		t.Fatalf("expected nosub error, got %v", err)
+	}
+}
+
+func TestClientSubscribeNosub(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	c, session := connectTestClient(t, srv)
+	defer c.Close()
+
+	// Stopped by server before ready, with and without an error
+	for _, nosubErr := range []*Error{{Code: "denied", Reason: "not allowed"}, nil} {
+		subscribed := make(chan error, 1)
+		go func() {
+			sub, err := c.Subscribe(testContext(t), "tasks")
+			if sub != nil {
+				t.Errorf("expected no subscription once stopped, got %+v", sub)
+			}
+			subscribed <- err
+		}()
+		msg := recvTestMessage(t, session)
+		sendTestMessage(t, session, &message{Msg: "nosub", ID: msg.ID, Error: nosubErr})
+		err := <-subscribed
+		var derr *Error
+		if nosubErr != nil && (!errors.As(err, &derr) || derr.Reason != "not allowed") {
+			t.Fatalf("expected nosub error subscribing, got %v", err)
+		} else if nosubErr == nil && err != ErrSubscriptionStopped {
+			t.Fatalf("expected ErrSubscriptionStopped subscribing, got %v", err)
+		}
+	}
+}
+
+func TestClientSubscribeCancel(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	c, session := connectTestClient(t, srv)
+	defer c.Close()
+
+	// Unsubscribed once ctx done before ready
+	ctx, cncl := context.WithCancel(testContext(t))
+	subscribed := make(chan error, 1)
+	go func() {
+		_, err := c.Subscribe(ctx, "tasks")
+		subscribed <- err
+	}()
+	msg := recvTestMessage(t, session)
+	cncl()
+	if err := <-subscribed; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled subscribing, got %v", err)
+	}
+	if unsub := recvTestMessage(t, session); unsub.Msg != "unsub" || unsub.ID != msg.ID {
+		t.Fatalf("unsub message was not as expected: %+v", unsub)
+	}
+}
+
+func TestClientPing(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	c, session := connectTestClient(t, srv)
+	defer c.Close()
+
+	// Server pings answered
+	sendTestMessage(t, session, &message{Msg: "ping", ID: "p1"})
+	if msg := recvTestMessage(t, session); msg.Msg != "pong" || msg.ID != "p1" {
+		t.Fatalf("pong message was not as expected: %+v", msg)
+	}
+
+	// Client pings await matching pong
+	pinged := make(chan error, 1)
+	go func() { pinged <- c.Ping(testContext(t)) }()
+	msg := recvTestMessage(t, session)
+	if msg.Msg != "ping" || msg.ID == "" {
+		t.Fatalf("ping message was not as expected: %+v", msg)
+	}
+	sendTestMessage(t, session, &message{Msg: "pong", ID: "other"})
+	select {
+	case err := <-pinged:
+		t.Fatalf("expected ping to await own pong, got %v", err)
+	case <-time.After(time.Millisecond * 20):
+	}
+	sendTestMessage(t, session, &message{Msg: "pong", ID: msg.ID})
+	if err := <-pinged; err != nil {
+		t.Fatalf("error pinging: %v", err)
+	}
+
+	// Pings fail once the client is done
+	go func() { pinged <- c.Ping(testContext(t)) }()
+	recvTestMessage(t, session)
+	session.Close(sockjsclient.CloseGoAway, "Go away!")
+	if err := <-pinged; !sockjsclient.IsGoAway(err) {
+		t.Fatalf("expected go away close error pinging, got %v", err)
+	}
+}
+
+// newTestClient returns a ddp client for srv
+func newTestClient(srv *sockjstest.Server) *Client {
+	return &Client{
+		SockJS: &sockjsclient.Client{
+			Address:    srv.URL,
+			Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+		},
+	}
+}
+
+// connectTestClient connects a ddp client to srv, returning it with its accepted session
+func connectTestClient(t *testing.T, srv *sockjstest.Server) (*Client, *sockjstest.Session) {
+	c := newTestClient(srv)
+	connected := make(chan error, 1)
+	go func() { connected <- c.ConnectContext(testContext(t)) }()
+
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+	recvTestMessage(t, session)
+	sendTestMessage(t, session, &message{Msg: "connected", Session: "s1"})
+	if err := <-connected; err != nil {
+		t.Fatalf("error connecting ddp client: %v", err)
+	}
+	return c, session
+}
+
+// sendTestMessage sends msg to the client over session
+func sendTestMessage(t *testing.T, session *sockjstest.Session, msg *message) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("error encoding message: %v", err)
+	}
+	session.Send(string(b))
+}
+
+// recvTestMessage receives the next message sent by the client over session
+func recvTestMessage(t *testing.T, session *sockjstest.Session) *message {
+	b, err := session.Recv(testContext(t))
+	if err != nil {
+		t.Fatalf("error receiving message from client: %v", err)
+	}
+	msg := &message{}
+	if err := json.Unmarshal([]byte(b), msg); err != nil {
+		t.Fatalf("error decoding message from client: %v", err)
+	}
+	return msg
+}
+
+// testContext returns a context bounding a single test step
+func testContext(t *testing.T) context.Context {
+	ctx, cncl := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cncl)
+	return ctx
+}
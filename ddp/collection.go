@@ -0,0 +1,116 @@
+package ddp
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// Document is a cached document's fields, each holding its EJSON encoded value
+type Document map[string]json.RawMessage
+
+// Decode JSON decodes the document fields into v
+func (d Document) Decode(v interface{}) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// copy returns a shallow copy of the document
+func (d Document) copy() Document {
+	cp := make(Document, len(d))
+	for key, value := range d {
+		cp[key] = value
+	}
+	return cp
+}
+
+// Collection is a local cache of a server collection, kept up to
+// date by the added, changed and removed messages of subscriptions
+type Collection struct {
+	Name string
+
+	docs map[string]Document // cached documents by id
+	mu   sync.RWMutex        // protects docs
+}
+
+// newCollection returns a new empty collection named name
+func newCollection(name string) *Collection {
+	return &Collection{
+		Name: name,
+		docs: map[string]Document{},
+	}
+}
+
+// Get returns a copy of the document with id, and whether it was found
+func (c *Collection) Get(id string) (Document, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	doc, ok := c.docs[id]
+	if !ok {
+		return nil, false
+	}
+	return doc.copy(), true
+}
+
+// IDs returns the ids of all cached documents, sorted
+func (c *Collection) IDs() []string {
+	c.mu.RLock()
+	ids := make([]string, 0, len(c.docs))
+	for id := range c.docs {
+		ids = append(ids, id)
+	}
+	c.mu.RUnlock()
+	sort.Strings(ids)
+	return ids
+}
+
+// Len returns the number of cached documents
+func (c *Collection) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.docs)
+}
+
+// apply updates the cache from an added, changed or removed message
+func (c *Collection) apply(msg *message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch msg.Msg {
+	// New document, replaces any existing
+	case "added", "addedBefore":
+		doc := make(Document, len(msg.Fields))
+		for key, value := range msg.Fields {
+			doc[key] = value
+		}
+		c.docs[msg.ID] = doc
+
+	// Set and clear fields of existing document
+	case "changed":
+		doc, ok := c.docs[msg.ID]
+		if !ok {
+			doc = Document{}
+			c.docs[msg.ID] = doc
+		}
+		for key, value := range msg.Fields {
+			doc[key] = value
+		}
+		for _, key := range msg.Cleared {
+			delete(doc, key)
+		}
+
+	// Drop document
+	case "removed":
+		delete(c.docs, msg.ID)
+	}
+}
+
+// clear drops all cached documents
+func (c *Collection) clear() {
+	c.mu.Lock()
+	c.docs = map[string]Document{}
+	c.mu.Unlock()
+}
@@ -0,0 +1,46 @@
+package ddp
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCollectionApply(t *testing.T) {
+	coll := newCollection("tasks")
+
+	for _, data := range []string{
+		`{"msg":"added","collection":"tasks","id":"a","fields":{"text":"one","done":false}}`,
+		`{"msg":"added","collection":"tasks","id":"b","fields":{"text":"two"}}`,
+		`{"msg":"changed","collection":"tasks","id":"a","fields":{"done":true},"cleared":["text"]}`,
+		`{"msg":"removed","collection":"tasks","id":"b"}`,
+	} {
+		msg := &message{}
+		if err := json.Unmarshal([]byte(data), msg); err != nil {
+			t.Fatalf("error decoding message: %v", err)
+		}
+		coll.apply(msg)
+	}
+
+	if ids := coll.IDs(); !reflect.DeepEqual(ids, []string{"a"}) {
+		t.Fatalf("cached ids were not as expected: %v", ids)
+	}
+
+	doc, ok := coll.Get("a")
+	if !ok {
+		t.Fatal("expected document a to be cached")
+	}
+	var v map[string]interface{}
+	if err := doc.Decode(&v); err != nil {
+		t.Fatalf("error decoding document: %v", err)
+	}
+	if exp := map[string]interface{}{"done": true}; !reflect.DeepEqual(v, exp) {
+		t.Fatalf("cached document was not as expected: {Expect=%v Got=%v}", exp, v)
+	}
+
+	// Returned documents are copies
+	doc["done"] = json.RawMessage("false")
+	if doc, _ := coll.Get("a"); string(doc["done"]) != "true" {
+		t.Fatal("modifying returned document changed cache")
+	}
+}
@@ -0,0 +1,67 @@
+package ddp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// message is a DDP message, as sent and received. Fields
+// are set as relevant to each message type
+type message struct {
+	Msg string `json:"msg"`
+	ID  string `json:"id,omitempty"`
+
+	// connect / connected / failed
+	Session string   `json:"session,omitempty"`
+	Version string   `json:"version,omitempty"`
+	Support []string `json:"support,omitempty"`
+
+	// method / sub
+	Method string        `json:"method,omitempty"`
+	Name   string        `json:"name,omitempty"`
+	Params []interface{} `json:"params,omitempty"`
+
+	// result / updated / ready / nosub
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	Methods []string        `json:"methods,omitempty"`
+	Subs    []string        `json:"subs,omitempty"`
+
+	// added / changed / removed
+	Collection string                     `json:"collection,omitempty"`
+	Fields     map[string]json.RawMessage `json:"fields,omitempty"`
+	Cleared    []string                   `json:"cleared,omitempty"`
+
+	// error
+	Reason           string          `json:"reason,omitempty"`
+	OffendingMessage json.RawMessage `json:"offendingMessage,omitempty"`
+}
+
+// Error is a DDP error, as returned by method calls and subscriptions
+type Error struct {
+	// Code is the error code, a string or number
+	Code interface{} `json:"error"`
+
+	Reason    string `json:"reason,omitempty"`
+	Details   string `json:"details,omitempty"`
+	Message   string `json:"message,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return "ddp: " + e.Message
+	}
+	return fmt.Sprintf("ddp: %s [%v]", e.Reason, e.Code)
+}
+
+// ProtocolError is returned on receiving an "error" message, sent by the server
+// in response to a message it could not process
+type ProtocolError struct {
+	Reason           string
+	OffendingMessage json.RawMessage
+}
+
+func (e *ProtocolError) Error() string {
+	return "ddp: protocol error: " + e.Reason
+}
@@ -0,0 +1,79 @@
+package ddp
+
+import (
+	"context"
+	"sync"
+)
+
+// Subscription represents a subscription to a record set
+type Subscription struct {
+	ID     string
+	Name   string
+	Params []interface{}
+
+	client *Client       // owning client
+	err    error         // nosub error, set on done
+	ready  chan struct{} // closed on ready (or done)
+	done   chan struct{} // closed on nosub or unsubscribe
+	ronce  sync.Once     // protects ready
+	donce  sync.Once     // protects done, err
+}
+
+// message returns the sub message for this subscription
+func (sub *Subscription) message() *message {
+	return &message{
+		Msg:    "sub",
+		ID:     sub.ID,
+		Name:   sub.Name,
+		Params: sub.Params,
+	}
+}
+
+// setReady marks the subscription ready
+func (sub *Subscription) setReady() {
+	sub.ronce.Do(func() { close(sub.ready) })
+}
+
+// stop marks the subscription stopped, with the nosub error if any
+func (sub *Subscription) stop(err *Error) {
+	sub.donce.Do(func() {
+		if err != nil {
+			sub.err = err
+		}
+		close(sub.done)
+	})
+	sub.setReady()
+}
+
+// Ready returns a channel that is closed once the subscription's initial records
+// have been sent to the collection caches, or the subscription stopped
+func (sub *Subscription) Ready() <-chan struct{} {
+	return sub.ready
+}
+
+// Done returns a channel that is closed once the subscription is stopped,
+// either by the server (nosub) or Unsubscribe
+func (sub *Subscription) Done() <-chan struct{} {
+	return sub.done
+}
+
+// Err returns the error the server stopped the subscription with as an *Error,
+// nil if none or not stopped
+func (sub *Subscription) Err() error {
+	select {
+	case <-sub.done:
+		return sub.err
+	default:
+		return nil
+	}
+}
+
+// Unsubscribe stops the subscription
+func (sub *Subscription) Unsubscribe() error {
+	c := sub.client
+	c.mu.Lock()
+	delete(c.subs, sub.ID)
+	c.mu.Unlock()
+	sub.stop(nil)
+	return c.write(context.Background(), &message{Msg: "unsub", ID: sub.ID})
+}
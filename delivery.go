@@ -0,0 +1,130 @@
+package sockjsclient
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a single data message received by a Client
+type Message struct {
+	// Data is the message payload
+	Data []byte
+
+	// Received is the time the message was read from the connection
+	Received time.Time
+
+	// Transport is the transport the message was received over
+	Transport Transport
+}
+
+// pump reads messages from a Client for its connected lifetime, delivering
+// each to the Client's OnMessage callback if set, else the msgs channel
+type pump struct {
+	msgs chan Message // delivered messages, closed once stopped
+}
+
+// run reads and delivers messages until reading fails or life is done
+func (p *pump) run(c *Client, life context.Context) {
+	defer close(p.msgs)
+
+	for {
+		// Read next message (reconnecting as per policy)
+		b, err := c.ReadMsgContext(life)
+		if err != nil {
+			return // closed by Close, or lost for good
+		}
+		msg := Message{
+			Data:      b,
+			Received:  time.Now(),
+			Transport: c.Transport(),
+		}
+
+		// Pass to callback if set
+		c.mu.Lock()
+		fn := c.onMsg
+		c.mu.Unlock()
+		if fn != nil {
+			fn(msg.Data)
+			continue
+		}
+
+		// Else deliver to channel
+		select {
+		case p.msgs <- msg:
+		case <-life.Done():
+			return
+		}
+	}
+}
+
+// pump returns the message delivery pump for the current connection, starting it if needed
+func (c *Client) pump() *pump {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pmp != nil {
+		return c.pmp
+	}
+
+	p := &pump{msgs: make(chan Message)}
+	c.pmp = p
+
+	// Stop immediately if not connected
+	if c.life == nil || c.life.Err() != nil {
+		close(p.msgs)
+		return p
+	}
+
+	go p.run(c, c.life)
+	return p
+}
+
+// Messages returns a channel delivering each message read from the connection, for
+// use in select statements, closed once the connection is closed or lost for good (see
+// Err). Reconnects are handled as in ReadMsg, which must not be used alongside
+func (c *Client) Messages() <-chan Message {
+	return c.pump().msgs
+}
+
+// OnMessage sets fn to be called with each message read from the connection, from a
+// single goroutine, in place of delivery via Messages. Reconnects are handled as in
+// ReadMsg, which must not be used alongside. A nil fn reverts to delivery via Messages
+func (c *Client) OnMessage(fn func([]byte)) {
+	c.mu.Lock()
+	c.onMsg = fn
+	c.mu.Unlock()
+	c.pump()
+}
+
+// Done returns a channel that is closed once the current connection is closed by Close,
+// or lost for good (i.e. failing while reading, with no reconnect possible). It does not
+// start message delivery, so loss is only seen once read via ReadMsg, Messages or
+// OnMessage. If never connected a closed channel is returned
+func (c *Client) Done() <-chan struct{} {
+	c.mu.Lock()
+	life := c.life
+	c.mu.Unlock()
+	if life == nil {
+		return closedChan
+	}
+	return life.Done()
+}
+
+// Err returns the error that ended the last connection, ErrClosedConnection if closed
+// by Close. It is nil until Done is first closed, and is kept across ConnectContext
+// until the new connection ends, so may still be read after Done should a new
+// connection be made meanwhile. ErrClientNotConnected if never connected
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.life == nil {
+		return ErrClientNotConnected
+	}
+	return c.lerr
+}
+
+// closedChan is a closed channel
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
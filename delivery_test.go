@@ -0,0 +1,124 @@
+package sockjsclient_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestClientMessages(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	client, session := connectTestClient(t, srv, sockjsclient.TransportWebsocket)
+
+	session.Send("a", "b")
+	for _, exp := range []string{"a", "b"} {
+		select {
+		case msg := <-client.Messages():
+			if string(msg.Data) != exp || msg.Transport != sockjsclient.TransportWebsocket || msg.Received.IsZero() {
+				t.Fatalf("message from server was not as expected: {Expect=%q Message=%+v}", exp, msg)
+			}
+		case <-testContext(t).Done():
+			t.Fatalf("timed out waiting for message %q", exp)
+		}
+	}
+
+	// Closed on Close
+	if err := client.Err(); err != nil {
+		t.Fatalf("expected no error before close, got %v", err)
+	}
+	client.Close()
+	for range client.Messages() {
+	}
+	<-client.Done()
+	if err := client.Err(); !errors.Is(err, sockjsclient.ErrClosedConnection) {
+		t.Fatalf("expected ErrClosedConnection after close, got %v", err)
+	}
+}
+
+func TestClientOnMessage(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	client, session := connectTestClient(t, srv, sockjsclient.TransportXHRStreaming)
+	defer client.Close()
+
+	msgs := make(chan string, 1)
+	client.OnMessage(func(b []byte) { msgs <- string(b) })
+
+	session.Send("hello")
+	select {
+	case msg := <-msgs:
+		if msg != "hello" {
+			t.Fatalf("message from server was not as expected: {Expect=%q Message=%q}", "hello", msg)
+		}
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for message")
+	}
+
+	// Lost for good on remote close
+	session.Close(sockjsclient.CloseGoAway, "Go away!")
+	select {
+	case <-client.Done():
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for done")
+	}
+	if err := client.Err(); !sockjsclient.IsGoAway(err) {
+		t.Fatalf("expected go away close error, got %v", err)
+	}
+}
+
+func TestClientDoneWithoutDelivery(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	client, session := connectTestClient(t, srv, sockjsclient.TransportWebsocket)
+	defer client.Close()
+
+	// Done does not start delivery, so messages are still read
+	done := client.Done()
+	session.Send("hello")
+	if msg, err := client.ReadMsgContext(testContext(t)); err != nil || string(msg) != "hello" {
+		t.Fatalf("expected message read alongside Done, got %q (err=%v)", msg, err)
+	}
+
+	// Loss is seen by read
+	session.Close(sockjsclient.CloseGoAway, "Go away!")
+	if _, err := client.ReadMsgContext(testContext(t)); !sockjsclient.IsGoAway(err) {
+		t.Fatalf("expected go away close error, got %v", err)
+	}
+	<-done
+	if err := client.Err(); !sockjsclient.IsGoAway(err) {
+		t.Fatalf("expected go away close error, got %v", err)
+	}
+}
+
+func TestClientErrAcrossConnect(t *testing.T) {
+	client := &sockjsclient.Client{}
+	<-client.Done()
+	if err := client.Err(); !sockjsclient.IsNotConnected(err) {
+		t.Fatalf("expected not connected error, got %v", err)
+	}
+
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	client, _ = connectTestClient(t, srv, sockjsclient.TransportWebsocket)
+	done := client.Done()
+	client.Close()
+
+	// Terminal error kept once connected anew (under a new session)
+	client.SessionID = ""
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error reconnecting to sockjs test server: %v", err)
+	}
+	defer client.Close()
+	<-done
+	if err := client.Err(); !errors.Is(err, sockjsclient.ErrClosedConnection) {
+		t.Fatalf("expected ErrClosedConnection kept after connect, got %v", err)
+	}
+	select {
+	case <-client.Done():
+		t.Fatal("expected new connection not done")
+	default:
+	}
+}
@@ -1,8 +1,138 @@
 package sockjsclient
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sockjs session close codes sent by servers
+const (
+	CloseInterrupted  = 1002 // "Connection interrupted"
+	CloseSessionTaken = 2010 // "Another connection still open"
+	CloseGoAway       = 3000 // "Go away!"
+)
 
 // IsNotConnected will return if this is a client / conn not connected error
 func IsNotConnected(err error) bool {
 	return errors.Is(err, ErrClosedConnection) || errors.Is(err, ErrClientNotConnected)
 }
+
+// IsGoAway will return if this is a remote "Go away!" session close error
+func IsGoAway(err error) bool {
+	code, ok := CloseCode(err)
+	return ok && code == CloseGoAway
+}
+
+// IsSessionTaken will return if this is a remote "Another connection still open" session close error
+func IsSessionTaken(err error) bool {
+	code, ok := CloseCode(err)
+	return ok && code == CloseSessionTaken
+}
+
+// IsInterrupted will return if this is a remote "Connection interrupted" session close error
+func IsInterrupted(err error) bool {
+	code, ok := CloseCode(err)
+	return ok && code == CloseInterrupted
+}
+
+// CloseCode returns the sockjs close code for this error, if it is a remote session close error
+func CloseCode(err error) (int, bool) {
+	var cerr *CloseError
+	if !errors.As(err, &cerr) {
+		return 0, false
+	}
+	return cerr.Code, true
+}
+
+// CloseError represents a session close frame received from the server,
+// matching ErrClosedByRemote with errors.Is
+type CloseError struct {
+	// Code is the sockjs close code, e.g. CloseGoAway
+	Code int
+
+	// Reason is the human readable close reason
+	Reason string
+}
+
+// Error implements error
+func (err *CloseError) Error() string {
+	return fmt.Sprintf("%s (%d, %s)", ErrClosedByRemote.Error(), err.Code, err.Reason)
+}
+
+// Is returns whether target is ErrClosedByRemote
+func (err *CloseError) Is(target error) bool {
+	return target == ErrClosedByRemote
+}
+
+// TransportError represents a failed attempt to connect using a single transport
+type TransportError struct {
+	// Transport is the attempted transport
+	Transport Transport
+
+	// Err is the error returned dialing the transport
+	Err error
+}
+
+// Error implements error
+func (err *TransportError) Error() string {
+	return string(err.Transport) + ": " + err.Err.Error()
+}
+
+// Unwrap returns the underlying dial error
+func (err *TransportError) Unwrap() error {
+	return err.Err
+}
+
+// ConnectError represents a failure to connect to the info endpoint, or using any
+// of the allowed transports, matching ErrClientCannotConnect with errors.Is
+type ConnectError struct {
+	// Info is the error fetching server info, if this is what failed
+	Info error
+
+	// Attempts holds the error for each attempted transport, in order
+	Attempts []*TransportError
+}
+
+// Error implements error
+func (err *ConnectError) Error() string {
+	if err.Info != nil {
+		return ErrClientCannotConnect.Error() + ": connecting to info endpoint: " + err.Info.Error()
+	}
+	if len(err.Attempts) == 0 {
+		return ErrClientCannotConnect.Error() + ": no allowed transports available"
+	}
+	errs := make([]string, 0, len(err.Attempts))
+	for _, attempt := range err.Attempts {
+		errs = append(errs, attempt.Error())
+	}
+	return ErrClientCannotConnect.Error() + ": connecting to transport endpoints: " + strings.Join(errs, ", ")
+}
+
+// Is returns whether target is ErrClientCannotConnect, or matches any attempt error
+func (err *ConnectError) Is(target error) bool {
+	if target == ErrClientCannotConnect {
+		return true
+	}
+	for _, attempt := range err.Attempts {
+		if errors.Is(attempt, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first attempt error in order matching target, setting target to it
+func (err *ConnectError) As(target interface{}) bool {
+	for _, attempt := range err.Attempts {
+		if errors.As(attempt, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the error fetching server info, if any
+func (err *ConnectError) Unwrap() error {
+	return err.Info
+}
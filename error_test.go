@@ -0,0 +1,48 @@
+package sockjsclient_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestCloseError(t *testing.T) {
+	for _, tc := range []struct {
+		err                           error
+		code                          int
+		closed                        bool
+		goAway, sessTaken, interrupts bool
+	}{
+		{err: &sockjsclient.CloseError{Code: 3000, Reason: "Go away!"}, code: 3000, closed: true, goAway: true},
+		{err: fmt.Errorf("reading: %w", &sockjsclient.CloseError{Code: 2010}), code: 2010, closed: true, sessTaken: true},
+		{err: &sockjsclient.CloseError{Code: 1002}, code: 1002, closed: true, interrupts: true},
+		{err: &sockjsclient.CloseError{Code: 4000}, code: 4000, closed: true},
+		{err: sockjsclient.ErrClosedConnection},
+		{err: nil},
+	} {
+		code, ok := sockjsclient.CloseCode(tc.err)
+		if code != tc.code || ok != tc.closed || errors.Is(tc.err, sockjsclient.ErrClosedByRemote) != tc.closed {
+			t.Fatalf("close code of %v was not as expected: {Expect=%d,%v Got=%d,%v}", tc.err, tc.code, tc.closed, code, ok)
+		}
+		if sockjsclient.IsGoAway(tc.err) != tc.goAway || sockjsclient.IsSessionTaken(tc.err) != tc.sessTaken || sockjsclient.IsInterrupted(tc.err) != tc.interrupts {
+			t.Fatalf("close kind of %v was not as expected", tc.err)
+		}
+	}
+}
+
+func TestClientCloseError(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	client, session := connectTestClient(t, srv, sockjsclient.TransportXHRPolling)
+	defer client.Close()
+
+	session.Close(sockjsclient.CloseSessionTaken, "Another connection still open")
+	_, err := client.ReadMsgContext(testContext(t))
+	var cerr *sockjsclient.CloseError
+	if !errors.As(err, &cerr) || cerr.Code != sockjsclient.CloseSessionTaken || cerr.Reason != "Another connection still open" {
+		t.Fatalf("expected session taken close error, got %v", err)
+	}
+}
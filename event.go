@@ -0,0 +1,103 @@
+package sockjsclient
+
+// EventType represents a type of connection lifecycle event
+type EventType uint8
+
+// Connection lifecycle event types
+const (
+	// EventConnecting is emitted before attempting to connect using a transport
+	EventConnecting = EventType(iota)
+
+	// EventOpen is emitted on receiving the session open frame, once connected
+	EventOpen
+
+	// EventFallback is emitted on failing to connect using a transport,
+	// before falling back to the next allowed transport
+	EventFallback
+
+	// EventHeartbeat is emitted on receiving a heartbeat frame
+	EventHeartbeat
+
+	// EventClose is emitted on receiving a session close frame
+	EventClose
+
+	// EventDisconnect is emitted when a connection's read loop exits
+	EventDisconnect
+
+	// EventReconnecting is emitted before each reconnect attempt
+	EventReconnecting
+
+	// EventReconnected is emitted on successfully reconnecting
+	EventReconnected
+)
+
+// String returns a string representation of event type
+func (t EventType) String() string {
+	switch t {
+	case EventConnecting:
+		return "connecting"
+	case EventOpen:
+		return "open"
+	case EventFallback:
+		return "fallback"
+	case EventHeartbeat:
+		return "heartbeat"
+	case EventClose:
+		return "close"
+	case EventDisconnect:
+		return "disconnect"
+	case EventReconnecting:
+		return "reconnecting"
+	case EventReconnected:
+		return "reconnected"
+	default:
+		return "unknown"
+	}
+}
+
+// Event represents a single connection lifecycle event
+type Event struct {
+	// Type is the type of event
+	Type EventType
+
+	// Transport is the transport in use (or being attempted)
+	Transport Transport
+
+	// Code and Reason are the close code and reason for EventClose
+	Code   int
+	Reason string
+
+	// Attempt is the reconnect attempt number for EventReconnecting / EventReconnected
+	Attempt int
+
+	// Err is the associated error for EventFallback, EventDisconnect and EventReconnecting
+	Err error
+}
+
+// eventFunc is an optional event hook, safe to call when nil
+type eventFunc func(Event)
+
+// emit passes the event to the hook, if set
+func (fn eventFunc) emit(ev Event) {
+	if fn != nil {
+		fn(ev)
+	}
+}
+
+// frame emits the event (if any) corresponding to a received sockjs frame
+func (fn eventFunc) frame(data []byte) {
+	if fn == nil || len(data) == 0 {
+		return
+	}
+
+	switch data[0] {
+	// Heartbeat
+	case 'h':
+		fn(Event{Type: EventHeartbeat})
+
+	// Session closed
+	case 'c':
+		code, reason, _ := parseCloseFrame(data)
+		fn(Event{Type: EventClose, Code: code, Reason: reason})
+	}
+}
@@ -0,0 +1,174 @@
+package sockjsclient_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestClientEventOrder(t *testing.T) {
+	srv := newRefusingServer(sockjsclient.TransportWebsocket)
+	defer srv.Close()
+
+	var events []string
+	var mu sync.Mutex
+	client := &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket, sockjsclient.TransportXHRStreaming},
+		Reconnect:  &sockjsclient.ReconnectPolicy{InitialDelay: time.Millisecond},
+		OnEvent: func(ev sockjsclient.Event) {
+			s := ev.Type.String() + " " + string(ev.Transport)
+			switch ev.Type {
+			case sockjsclient.EventClose:
+				s += fmt.Sprintf(" %d", ev.Code)
+			case sockjsclient.EventReconnecting, sockjsclient.EventReconnected:
+				s += fmt.Sprintf(" %d", ev.Attempt)
+			}
+			mu.Lock()
+			events = append(events, s)
+			mu.Unlock()
+		},
+	}
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
+	defer client.Close()
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+
+	// Heartbeat then interrupt, reading through the reconnect
+	session.Heartbeat()
+	session.Close(1002, "Connection interrupted")
+	go client.ReadMsgContext(testContext(t))
+	if _, err := srv.Accept(testContext(t)); err != nil {
+		t.Fatalf("error accepting reconnected session: %v", err)
+	}
+
+	exp := []string{
+		"connecting websocket",
+		"fallback websocket",
+		"connecting xhr-streaming",
+		"open xhr-streaming",
+		"heartbeat xhr-streaming",
+		"close xhr-streaming 1002",
+		"disconnect xhr-streaming",
+		"reconnecting  1",
+		"connecting websocket",
+		"fallback websocket",
+		"connecting xhr-streaming",
+		"open xhr-streaming",
+		"reconnected  1",
+	}
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		mu.Lock()
+		got := append([]string(nil), events...)
+		mu.Unlock()
+		if len(got) >= len(exp) || time.Now().After(deadline) {
+			if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+				t.Fatalf("events were not as expected:\nExpect:\n%s\nGot:\n%s", strings.Join(exp, "\n"), strings.Join(got, "\n"))
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestClientEventHeartbeatTimeout(t *testing.T) {
+	for _, transport := range []sockjsclient.Transport{
+		sockjsclient.TransportWebsocket,
+		sockjsclient.TransportXHRPolling,
+		sockjsclient.TransportXHRStreaming,
+		sockjsclient.TransportJSONPPolling,
+	} {
+		t.Run(string(transport), func(t *testing.T) {
+			srv := sockjstest.NewServer()
+			defer srv.Close()
+
+			events := make(chan sockjsclient.Event, 64)
+			client := &sockjsclient.Client{
+				Address:          srv.URL,
+				Transports:       []sockjsclient.Transport{transport},
+				HeartbeatTimeout: time.Millisecond * 100,
+				Reconnect:        &sockjsclient.ReconnectPolicy{InitialDelay: time.Millisecond},
+				OnEvent: func(ev sockjsclient.Event) {
+					if ev.Type == sockjsclient.EventDisconnect || ev.Type == sockjsclient.EventReconnecting {
+						events <- ev
+					}
+				},
+			}
+			if err := client.ConnectContext(testContext(t)); err != nil {
+				t.Fatalf("error connecting to sockjs test server: %v", err)
+			}
+			defer client.Close()
+			if _, err := srv.Accept(testContext(t)); err != nil {
+				t.Fatalf("error accepting session: %v", err)
+			}
+
+			// No heartbeats sent, reading through the reconnect
+			go client.ReadMsgContext(testContext(t))
+			if _, err := srv.Accept(testContext(t)); err != nil {
+				t.Fatalf("error accepting reconnected session: %v", err)
+			}
+
+			// Disconnect reported with the timeout, before reconnecting
+			for _, exp := range []sockjsclient.EventType{sockjsclient.EventDisconnect, sockjsclient.EventReconnecting} {
+				select {
+				case ev := <-events:
+					if ev.Type != exp {
+						t.Fatalf("expected %s event, got %s", exp, ev.Type)
+					} else if ev.Type == sockjsclient.EventDisconnect && !errors.Is(ev.Err, sockjsclient.ErrNoHeartbeat) {
+						t.Fatalf("expected disconnect with ErrNoHeartbeat, got %v", ev.Err)
+					}
+				case <-testContext(t).Done():
+					t.Fatalf("timed out waiting for %s event", exp)
+				}
+			}
+		})
+	}
+}
+
+func TestClientEventHook(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	hooked := make(chan sockjsclient.Event, 16)
+	client := &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportXHRStreaming},
+		OnEvent:    func(sockjsclient.Event) {},
+	}
+	client.AddEventHook(func(ev sockjsclient.Event) { hooked <- ev })
+
+	// Hook kept when OnEvent is replaced
+	client.OnEvent = nil
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
+	defer client.Close()
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+
+	// Including conn events, with transport set
+	session.Heartbeat()
+	for _, exp := range []sockjsclient.EventType{sockjsclient.EventConnecting, sockjsclient.EventOpen, sockjsclient.EventHeartbeat} {
+		select {
+		case ev := <-hooked:
+			if ev.Type != exp || ev.Transport != sockjsclient.TransportXHRStreaming {
+				t.Fatalf("event was not as expected: {Expect=%v Event=%+v}", exp, ev)
+			}
+		case <-testContext(t).Done():
+			t.Fatalf("timed out waiting for %v event", exp)
+		}
+	}
+}
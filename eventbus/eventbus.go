@@ -0,0 +1,345 @@
+// Package eventbus implements a Vert.x event bus bridge client over a sockjs Client
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/internal/layer"
+)
+
+// Default event bus timings, as used by vertx-eventbus.js
+const (
+	DefaultPingInterval   = time.Second * 5
+	DefaultRequestTimeout = time.Second * 30
+)
+
+// Event bus error messages
+var (
+	ErrNotConnected   = errors.New("eventbus: not connected")
+	ErrNoReplyAddress = errors.New("eventbus: message has no reply address")
+)
+
+// EventBus is a Vert.x event bus bridge client layered over a sockjs Client, registering
+// all handlers again whenever the sockjs client reconnects (see sockjsclient.Client.Reconnect)
+type EventBus struct {
+	// SockJS is the sockjs client connected to the bridge, configured but not yet
+	// connected. Connect adds an event hook to it, to register handlers again on reconnect
+	SockJS *sockjsclient.Client
+
+	// PingInterval is the interval at which pings are sent to keep the bridge
+	// session alive. Zero means DefaultPingInterval, negative disables
+	PingInterval time.Duration
+
+	// RequestTimeout is the time to wait for a reply to Request, where the
+	// context has no deadline. Zero means DefaultRequestTimeout
+	RequestTimeout time.Duration
+
+	// OnError is called with any failure received not in reply to a request,
+	// e.g. a rejected send or registration
+	OnError func(*ReplyError)
+
+	handlers map[string][]*Registration // registered handlers by address
+	replies  map[string]chan *Message   // reply waiters by reply address
+	life     layer.Lifecycle            // read loop lifecycle
+	mu       sync.Mutex                 // protects handlers, replies
+}
+
+// Registration represents a handler registered to an address
+type Registration struct {
+	Address string
+
+	bus     *EventBus         // owning event bus
+	headers map[string]string // headers sent on registering
+	handler func(*Message)    // received message handler
+}
+
+func (eb *EventBus) Connect() error {
+	return eb.ConnectContext(context.Background())
+}
+
+// ConnectContext connects the sockjs client, giving up with ctx's error should it be done first
+func (eb *EventBus) ConnectContext(ctx context.Context) error {
+	// Register handlers again on each reconnect
+	eb.life.Hook(eb.SockJS, func(ev sockjsclient.Event) {
+		if ev.Type == sockjsclient.EventReconnected {
+			go eb.reregister()
+		}
+	})
+
+	// Connect underlying sockjs client
+	if err := eb.SockJS.ConnectContext(ctx); err != nil {
+		return err
+	}
+
+	// Set up new session
+	eb.mu.Lock()
+	eb.handlers = map[string][]*Registration{}
+	eb.replies = map[string]chan *Message{}
+	run := eb.life.Start()
+	eb.mu.Unlock()
+
+	go eb.readLoop(run)
+	go eb.pingLoop(run.Done())
+
+	return nil
+}
+
+// readLoop reads and dispatches messages until the sockjs client fails or is closed
+func (eb *EventBus) readLoop(run *layer.Run) {
+	run.Stop(eb.dispatch())
+}
+
+// dispatch reads messages, passing each to its reply waiter or handlers, until an error
+// occurs. Messages that are not valid event bus envelopes are skipped
+func (eb *EventBus) dispatch() error {
+	for {
+		b, err := eb.SockJS.ReadMsg()
+		if err != nil {
+			return err
+		}
+
+		m := &Message{}
+		if err := json.Unmarshal(b, m); err != nil {
+			continue // not an envelope, so for no address
+		}
+		m.bus = eb
+
+		// Pass to reply waiter
+		eb.mu.Lock()
+		ch := eb.replies[m.Address]
+		delete(eb.replies, m.Address)
+		regs := eb.handlers[m.Address]
+		eb.mu.Unlock()
+		if ch != nil {
+			ch <- m // buffered
+			continue
+		}
+
+		switch m.Type {
+		// Pass to registered handlers
+		case TypeRec:
+			for _, reg := range regs {
+				reg.handler(m)
+			}
+
+		// Unsolicited failure
+		case TypeErr:
+			if eb.OnError != nil {
+				eb.OnError(replyError(m))
+			}
+		}
+	}
+}
+
+// pingLoop sends pings at the configured interval until done
+func (eb *EventBus) pingLoop(done <-chan struct{}) {
+	interval := eb.PingInterval
+	if interval < 0 {
+		return
+	} else if interval == 0 {
+		interval = DefaultPingInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			eb.write(context.Background(), &Message{Type: TypePing}) // failure is seen by read loop
+		}
+	}
+}
+
+// reregister sends a register envelope for each address with registered handlers
+func (eb *EventBus) reregister() {
+	eb.mu.Lock()
+	regs := make([]*Registration, 0, len(eb.handlers))
+	for _, addrRegs := range eb.handlers {
+		regs = append(regs, addrRegs[0])
+	}
+	eb.mu.Unlock()
+
+	for _, reg := range regs {
+		eb.write(context.Background(), &Message{ // failure is seen by read loop
+			Type:    TypeRegister,
+			Address: reg.Address,
+			Headers: reg.headers,
+		})
+	}
+}
+
+// write sends an envelope to the bridge
+func (eb *EventBus) write(ctx context.Context, m *Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return eb.SockJS.WriteMsgContext(ctx, b)
+}
+
+// Send sends body (JSON encoded) to a single handler at address
+func (eb *EventBus) Send(address string, body interface{}, headers map[string]string) error {
+	m, err := newMessage(TypeSend, address, body, headers)
+	if err != nil {
+		return err
+	}
+	return eb.write(context.Background(), m)
+}
+
+// Publish sends body (JSON encoded) to all handlers at address
+func (eb *EventBus) Publish(address string, body interface{}, headers map[string]string) error {
+	m, err := newMessage(TypePublish, address, body, headers)
+	if err != nil {
+		return err
+	}
+	return eb.write(context.Background(), m)
+}
+
+// Request sends body (JSON encoded) to a single handler at address, then waits for its
+// reply. A failure reply is returned as a *ReplyError. Should ctx have no deadline,
+// the request times out after RequestTimeout with context.DeadlineExceeded
+func (eb *EventBus) Request(ctx context.Context, address string, body interface{}, headers map[string]string) (*Message, error) {
+	m, err := newMessage(TypeSend, address, body, headers)
+	if err != nil {
+		return nil, err
+	}
+	m.ReplyAddress = uuid.Must(uuid.NewV4()).String()
+
+	// Apply default timeout
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := eb.RequestTimeout
+		if timeout <= 0 {
+			timeout = DefaultRequestTimeout
+		}
+		var cncl func()
+		ctx, cncl = context.WithTimeout(ctx, timeout)
+		defer cncl()
+	}
+
+	// Register reply waiter
+	ch := make(chan *Message, 1)
+	eb.mu.Lock()
+	if eb.replies == nil {
+		eb.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	eb.replies[m.ReplyAddress] = ch
+	done := eb.life.Done()
+	eb.mu.Unlock()
+	defer func() {
+		eb.mu.Lock()
+		delete(eb.replies, m.ReplyAddress)
+		eb.mu.Unlock()
+	}()
+
+	if err := eb.write(ctx, m); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-ch:
+		if reply.Type == TypeErr {
+			return nil, replyError(reply)
+		}
+		return reply, nil
+	case <-done:
+		return nil, eb.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Register registers handler to receive messages sent or published to address. Handlers
+// are called from the event bus read goroutine so must not block, nor call Request
+func (eb *EventBus) Register(address string, headers map[string]string, handler func(*Message)) (*Registration, error) {
+	reg := &Registration{
+		Address: address,
+		bus:     eb,
+		headers: headers,
+		handler: handler,
+	}
+
+	// Add handler, registering address with bridge if first
+	eb.mu.Lock()
+	if eb.handlers == nil {
+		eb.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	first := len(eb.handlers[address]) == 0
+	eb.handlers[address] = append(eb.handlers[address], reg)
+	eb.mu.Unlock()
+	if !first {
+		return reg, nil
+	}
+
+	err := eb.write(context.Background(), &Message{
+		Type:    TypeRegister,
+		Address: address,
+		Headers: headers,
+	})
+	if err != nil {
+		reg.remove()
+		return nil, err
+	}
+
+	return reg, nil
+}
+
+// remove removes this handler, returning whether it was the last for its address
+func (reg *Registration) remove() bool {
+	eb := reg.bus
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	regs := eb.handlers[reg.Address]
+	for i, r := range regs {
+		if r == reg {
+			regs = append(regs[:i:i], regs[i+1:]...)
+			break
+		}
+	}
+	if len(regs) == 0 {
+		delete(eb.handlers, reg.Address)
+		return true
+	}
+	eb.handlers[reg.Address] = regs
+	return false
+}
+
+// Unregister removes this handler, unregistering its address with the bridge if the last
+func (reg *Registration) Unregister() error {
+	if !reg.remove() {
+		return nil
+	}
+	return reg.bus.write(context.Background(), &Message{
+		Type:    TypeUnregister,
+		Address: reg.Address,
+		Headers: reg.headers,
+	})
+}
+
+// Done returns a channel that is closed once the event bus stops reading
+// messages, i.e. the sockjs client was closed or lost for good
+func (eb *EventBus) Done() <-chan struct{} {
+	return eb.life.Done()
+}
+
+// Err returns the error that stopped the event bus reading messages, nil until Done
+// is closed, or ErrNotConnected if never connected
+func (eb *EventBus) Err() error {
+	return eb.life.Err(ErrNotConnected)
+}
+
+// Close closes the sockjs client
+func (eb *EventBus) Close() error {
+	return eb.SockJS.Close()
+}
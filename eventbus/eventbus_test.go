@@ -0,0 +1,232 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestEventBusPublish(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	eb, session := connectTestEventBus(t, srv, nil)
+	defer eb.Close()
+
+	if err := eb.Publish("news.uk", "hello", map[string]string{"lang": "en"}); err != nil {
+		t.Fatalf("error publishing: %v", err)
+	}
+	if m := recvTestMessage(t, session); m.Type != TypePublish || m.Address != "news.uk" ||
+		string(m.Body) != `"hello"` || m.Headers["lang"] != "en" {
+		t.Fatalf("published message was not as expected: %+v", m)
+	}
+}
+
+func TestEventBusRegister(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	eb, session := connectTestEventBus(t, srv, nil)
+	defer eb.Close()
+
+	// Address registered with bridge once, for the first handler only
+	received := make(chan string, 4)
+	first, err := eb.Register("news.uk", nil, func(m *Message) { received <- "first " + string(m.Body) })
+	if err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+	if _, err := eb.Register("news.uk", nil, func(m *Message) { received <- "second " + string(m.Body) }); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+	if m := recvTestMessage(t, session); m.Type != TypeRegister || m.Address != "news.uk" {
+		t.Fatalf("register message was not as expected: %+v", m)
+	}
+
+	// Received messages passed to all handlers at address
+	sendTestMessage(t, session, &Message{Type: TypeRec, Address: "news.other", Body: json.RawMessage(`0`)})
+	sendTestMessage(t, session, &Message{Type: TypeRec, Address: "news.uk", Body: json.RawMessage(`1`)})
+	for _, exp := range []string{"first 1", "second 1"} {
+		select {
+		case got := <-received:
+			if got != exp {
+				t.Fatalf("handled message was not as expected: {Expect=%q Got=%q}", exp, got)
+			}
+		case <-testContext(t).Done():
+			t.Fatalf("timed out waiting for %q", exp)
+		}
+	}
+
+	// Removing one handler leaves address registered
+	if err := first.Unregister(); err != nil {
+		t.Fatalf("error unregistering: %v", err)
+	}
+	sendTestMessage(t, session, &Message{Type: TypeRec, Address: "news.uk", Body: json.RawMessage(`2`)})
+	if got := <-received; got != "second 2" {
+		t.Fatalf("handled message was not as expected: {Expect=%q Got=%q}", "second 2", got)
+	}
+}
+
+func TestEventBusMalformed(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	eb, session := connectTestEventBus(t, srv, nil)
+	defer eb.Close()
+
+	received := make(chan string, 1)
+	if _, err := eb.Register("news.uk", nil, func(m *Message) { received <- string(m.Body) }); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+	recvTestMessage(t, session)
+
+	// Malformed frame skipped, reading on
+	session.Send("not an envelope")
+	sendTestMessage(t, session, &Message{Type: TypeRec, Address: "news.uk", Body: json.RawMessage(`1`)})
+	select {
+	case got := <-received:
+		if got != "1" {
+			t.Fatalf("handled message was not as expected: {Expect=%q Got=%q}", "1", got)
+		}
+	case <-eb.Done():
+		t.Fatalf("expected malformed frame skipped, read loop ended with %v", eb.Err())
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for message after malformed frame")
+	}
+}
+
+func TestEventBusRequest(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	eb, session := connectTestEventBus(t, srv, nil)
+	defer eb.Close()
+
+	// Concurrent requests each receive their own reply
+	type result struct {
+		reply *Message
+		err   error
+	}
+	results := map[string]chan result{}
+	for _, addr := range []string{"a", "b"} {
+		ch := make(chan result, 1)
+		results[addr] = ch
+		go func(addr string) {
+			reply, err := eb.Request(testContext(t), addr, "ping", nil)
+			ch <- result{reply, err}
+		}(addr)
+	}
+	replyAddrs := map[string]string{}
+	for range results {
+		m := recvTestMessage(t, session)
+		if m.Type != TypeSend || m.ReplyAddress == "" {
+			t.Fatalf("request message was not as expected: %+v", m)
+		}
+		replyAddrs[m.Address] = m.ReplyAddress
+	}
+	sendTestMessage(t, session, &Message{Type: TypeRec, Address: replyAddrs["b"], Body: json.RawMessage(`"b"`)})
+	sendTestMessage(t, session, &Message{Type: TypeErr, Address: replyAddrs["a"], FailureCode: 7, FailureType: "RECIPIENT_FAILURE", Message: "nope"})
+
+	if res := <-results["b"]; res.err != nil || string(res.reply.Body) != `"b"` {
+		t.Fatalf("expected reply to b, got %+v (err=%v)", res.reply, res.err)
+	}
+	var rerr *ReplyError
+	if res := <-results["a"]; !errors.As(res.err, &rerr) || rerr.FailureCode != 7 {
+		t.Fatalf("expected failure reply to a, got %v", res.err)
+	}
+}
+
+func TestEventBusRequestTimeout(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	eb, session := connectTestEventBus(t, srv, nil)
+	defer eb.Close()
+
+	eb.RequestTimeout = time.Millisecond * 10
+	if _, err := eb.Request(context.Background(), "a", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected request timeout, got %v", err)
+	}
+
+	// Reply waiter removed
+	if m := recvTestMessage(t, session); m.ReplyAddress == "" {
+		t.Fatalf("request message was not as expected: %+v", m)
+	}
+	eb.mu.Lock()
+	n := len(eb.replies)
+	eb.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("expected no reply waiters left, got %d", n)
+	}
+}
+
+func TestEventBusReregister(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	eb, session := connectTestEventBus(t, srv, &sockjsclient.ReconnectPolicy{InitialDelay: time.Millisecond})
+	defer eb.Close()
+
+	if _, err := eb.Register("news.uk", map[string]string{"token": "x"}, func(*Message) {}); err != nil {
+		t.Fatalf("error registering: %v", err)
+	}
+	recvTestMessage(t, session)
+
+	// Handlers registered again once reconnected
+	session.Close(1002, "Connection interrupted")
+	resumed, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting reconnected session: %v", err)
+	}
+	if m := recvTestMessage(t, resumed); m.Type != TypeRegister || m.Address != "news.uk" || m.Headers["token"] != "x" {
+		t.Fatalf("register message was not as expected: %+v", m)
+	}
+}
+
+// connectTestEventBus connects an event bus (without pings) to srv, reconnecting
+// according to reconnect if set, returning it with its accepted session
+func connectTestEventBus(t *testing.T, srv *sockjstest.Server, reconnect *sockjsclient.ReconnectPolicy) (*EventBus, *sockjstest.Session) {
+	eb := &EventBus{
+		SockJS: &sockjsclient.Client{
+			Address:    srv.URL,
+			Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+			Reconnect:  reconnect,
+		},
+		PingInterval: -1,
+	}
+	if err := eb.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting event bus: %v", err)
+	}
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+	return eb, session
+}
+
+// sendTestMessage sends envelope m to the event bus over session
+func sendTestMessage(t *testing.T, session *sockjstest.Session, m *Message) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("error encoding message: %v", err)
+	}
+	session.Send(string(b))
+}
+
+// recvTestMessage receives the next envelope sent by the event bus over session
+func recvTestMessage(t *testing.T, session *sockjstest.Session) *Message {
+	msg, err := session.Recv(testContext(t))
+	if err != nil {
+		t.Fatalf("error receiving message from event bus: %v", err)
+	}
+	m := &Message{}
+	if err := json.Unmarshal([]byte(msg), m); err != nil {
+		t.Fatalf("error decoding message from event bus: %v", err)
+	}
+	return m
+}
+
+// testContext returns a context bounding a single test step
+func testContext(t *testing.T) context.Context {
+	ctx, cncl := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cncl)
+	return ctx
+}
@@ -0,0 +1,86 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Vert.x event bus bridge envelope types
+const (
+	TypeSend       = "send"
+	TypePublish    = "publish"
+	TypeRegister   = "register"
+	TypeUnregister = "unregister"
+	TypePing       = "ping"
+	TypeRec        = "rec"
+	TypeErr        = "err"
+)
+
+// Message is a Vert.x event bus bridge envelope, as sent and received
+type Message struct {
+	Type         string            `json:"type"`
+	Address      string            `json:"address,omitempty"`
+	ReplyAddress string            `json:"replyAddress,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         json.RawMessage   `json:"body,omitempty"`
+
+	// Failure fields, set on "err" envelopes only
+	FailureCode int    `json:"failureCode,omitempty"`
+	FailureType string `json:"failureType,omitempty"`
+	Message     string `json:"message,omitempty"`
+
+	bus *EventBus // receiving event bus, nil if not received
+}
+
+// Decode JSON decodes the message body into v
+func (m *Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Body, v)
+}
+
+// Reply sends body (JSON encoded) in reply to this received message, which must have a reply address
+func (m *Message) Reply(body interface{}, headers map[string]string) error {
+	if m.bus == nil || m.ReplyAddress == "" {
+		return ErrNoReplyAddress
+	}
+	return m.bus.Send(m.ReplyAddress, body, headers)
+}
+
+// ReplyError is returned on receiving a failure in reply to a request, or
+// passed to OnError for failures not in reply to any request
+type ReplyError struct {
+	Address     string
+	FailureCode int
+	FailureType string
+	Message     string
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("eventbus: %s (%s, code %d)", e.Message, e.FailureType, e.FailureCode)
+}
+
+// replyError returns the failure held by an "err" envelope
+func replyError(m *Message) *ReplyError {
+	return &ReplyError{
+		Address:     m.Address,
+		FailureCode: m.FailureCode,
+		FailureType: m.FailureType,
+		Message:     m.Message,
+	}
+}
+
+// newMessage returns a new envelope of type to address, with body JSON encoded
+func newMessage(typ, address string, body interface{}, headers map[string]string) (*Message, error) {
+	m := &Message{
+		Type:    typ,
+		Address: address,
+		Headers: headers,
+	}
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		m.Body = b
+	}
+	return m, nil
+}
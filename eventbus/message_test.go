@@ -0,0 +1,42 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestNewMessage(t *testing.T) {
+	m, err := newMessage(TypePublish, "news.uk", map[string]string{"title": "hello"}, map[string]string{"lang": "en"})
+	if err != nil {
+		t.Fatalf("error creating message: %v", err)
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("error encoding message: %v", err)
+	}
+	exp := `{"type":"publish","address":"news.uk","headers":{"lang":"en"},"body":{"title":"hello"}}`
+	if string(b) != exp {
+		t.Fatalf("encoded message was not as expected: {Expect=%s Got=%s}", exp, b)
+	}
+}
+
+func TestReceivedFailure(t *testing.T) {
+	m := &Message{}
+	data := `{"type":"err","address":"reply.1","failureCode":-1,"failureType":"NO_HANDLERS","message":"No handlers"}`
+	if err := json.Unmarshal([]byte(data), m); err != nil {
+		t.Fatalf("error decoding message: %v", err)
+	}
+
+	err := error(replyError(m))
+	var rerr *ReplyError
+	if !errors.As(err, &rerr) || rerr.FailureType != "NO_HANDLERS" || rerr.FailureCode != -1 {
+		t.Fatalf("reply error was not as expected: %v", err)
+	}
+
+	// Only received messages with a reply address can be replied to
+	if err := m.Reply("ok", nil); !errors.Is(err, ErrNoReplyAddress) {
+		t.Fatalf("expected ErrNoReplyAddress, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package sockjsclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+)
+
+type EventSourceDialer struct {
+	// HTTPClient is the underlying http.Client used by
+	// the produced EventSource conn
+	HTTPClient *http.Client
+
+	// ConnOptions are the options common to all transports
+	ConnOptions
+}
+
+func (d *EventSourceDialer) Dial(addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error) {
+	return d.DialContext(context.Background(), addr, serverID, sessionID, hdrs)
+}
+
+func (d *EventSourceDialer) DialContext(ctx context.Context, addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error) {
+	// Parse a valid transport address
+	taddr, err := parseTransportAddr(addr, serverID, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Ensure an HTTP client is set
+	if d.HTTPClient == nil {
+		d.HTTPClient = http.DefaultClient
+	}
+
+	// Prepare connection endpoints
+	readAddr := taddr + "/eventsource"
+	writeAddr := taddr + "/xhr_send"
+
+	// Request an event stream
+	hdrs = hdrs.Clone()
+	if hdrs == nil {
+		hdrs = http.Header{}
+	}
+	hdrs.Set("Accept", "text/event-stream")
+
+	// Attempt opening event stream connection
+	conn, rsp, err := dialStream(
+		ctx,
+		*d.HTTPClient,
+		http.MethodGet,
+		readAddr,
+		writeAddr,
+		hdrs,
+		readEventSourceFrame,
+		d.events,
+		d.HeartbeatTimeout,
+		newInbox(d.InboundBuffer, d.Overflow),
+	)
+	if err != nil {
+		return nil, rsp, err
+	}
+
+	return conn, rsp, nil
+}
+
+// readEventSourceFrame reads the next frame from a text/event-stream
+// response body, taken from a "data:" line. All other lines are skipped
+func readEventSourceFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		// Read next full line from stream
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		// Skip prelude, event separators and non-data fields
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+
+		// Strip field name and single optional space
+		line = bytes.TrimPrefix(line[len("data:"):], []byte{' '})
+		if len(line) == 0 {
+			continue
+		}
+
+		return line, nil
+	}
+}
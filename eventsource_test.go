@@ -0,0 +1,23 @@
+package sockjsclient
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadEventSourceFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\r\ndata: o\r\n\r\n: comment\r\nevent: x\r\ndata:\r\ndata:h\r\n\r\ndata: a[\"x y\"]\r\n\r\n"))
+	for _, exp := range []string{"o", "h", `a["x y"]`} {
+		frame, err := readEventSourceFrame(r)
+		if err != nil {
+			t.Fatalf("error reading frame %q: %v", exp, err)
+		} else if string(frame) != exp {
+			t.Fatalf("frame was not as expected: {Expect=%q Frame=%q}", exp, frame)
+		}
+	}
+	if _, err := readEventSourceFrame(r); err != io.EOF {
+		t.Fatalf("expected EOF at end of stream, got %v", err)
+	}
+}
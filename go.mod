@@ -5,5 +5,4 @@ go 1.14
 require (
 	github.com/gofrs/uuid v4.0.0+incompatible
 	github.com/gorilla/websocket v1.4.2
-	github.com/igm/sockjs-go/v3 v3.0.1
 )
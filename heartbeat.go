@@ -0,0 +1,51 @@
+package sockjsclient
+
+import "time"
+
+// DefaultHeartbeatInterval is the standard interval at which sockjs servers send heartbeat frames
+const DefaultHeartbeatInterval = time.Second * 25
+
+// DefaultHeartbeatTimeout is the default time allowed without receiving any frame from
+// the server before a conn is considered dead, allowing for a missed heartbeat or two
+const DefaultHeartbeatTimeout = DefaultHeartbeatInterval * 3
+
+// heartbeatTimeout resolves a configured heartbeat timeout, zero meaning
+// the default and negative meaning disabled (returned as-is)
+func heartbeatTimeout(timeout time.Duration) time.Duration {
+	if timeout == 0 {
+		return DefaultHeartbeatTimeout
+	}
+	return timeout
+}
+
+// watchdog calls its expire function should it not be fed within timeout
+type watchdog struct {
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+// newWatchdog returns a started watchdog for (resolved) heartbeat timeout, nil if disabled
+func newWatchdog(timeout time.Duration, expire func()) *watchdog {
+	timeout = heartbeatTimeout(timeout)
+	if timeout < 0 {
+		return nil
+	}
+	return &watchdog{
+		timer:   time.AfterFunc(timeout, expire),
+		timeout: timeout,
+	}
+}
+
+// feed resets the watchdog timeout, e.g. on receiving a frame
+func (w *watchdog) feed() {
+	if w != nil {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+// stop stops the watchdog
+func (w *watchdog) stop() {
+	if w != nil {
+		w.timer.Stop()
+	}
+}
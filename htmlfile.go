@@ -0,0 +1,78 @@
+package sockjsclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type HTMLFileDialer struct {
+	// HTTPClient is the underlying http.Client used by
+	// the produced htmlfile conn
+	HTTPClient *http.Client
+
+	// ConnOptions are the options common to all transports
+	ConnOptions
+}
+
+func (d *HTMLFileDialer) Dial(addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error) {
+	return d.DialContext(context.Background(), addr, serverID, sessionID, hdrs)
+}
+
+func (d *HTMLFileDialer) DialContext(ctx context.Context, addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error) {
+	// Parse a valid transport address
+	taddr, err := parseTransportAddr(addr, serverID, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Ensure an HTTP client is set
+	if d.HTTPClient == nil {
+		d.HTTPClient = http.DefaultClient
+	}
+
+	// Prepare connection endpoints
+	readAddr := taddr + "/htmlfile?c=" + url.QueryEscape(newCallbackName())
+	writeAddr := taddr + "/xhr_send"
+
+	// Attempt opening htmlfile streaming connection
+	conn, rsp, err := dialStream(
+		ctx,
+		*d.HTTPClient,
+		http.MethodGet,
+		readAddr,
+		writeAddr,
+		hdrs,
+		readHTMLFileFrame,
+		d.events,
+		d.HeartbeatTimeout,
+		newInbox(d.InboundBuffer, d.Overflow),
+	)
+	if err != nil {
+		return nil, rsp, err
+	}
+
+	return conn, rsp, nil
+}
+
+// readHTMLFileFrame reads the next frame from an htmlfile response body, taken
+// from a `p("...");` script line. The page header and script tags are skipped
+func readHTMLFileFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		// Read next full line from stream
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+
+		// Skip any lines not calling frame callback
+		if !bytes.HasPrefix(line, []byte("p(")) {
+			continue
+		}
+
+		return unwrapCallback(line, "p")
+	}
+}
@@ -0,0 +1,143 @@
+package sockjsclient
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// inbox is a conn's queue of inbound messages, tracking the terminal error
+// the conn failed with so that it is only delivered after queued messages
+type inbox struct {
+	msgs    chan []byte    // queued inbound messages
+	policy  OverflowPolicy // handling of messages when full
+	dropped uint64         // count of dropped messages, accessed atomically
+	done    chan struct{}  // closed on conn failure
+	err     error          // terminal conn error
+	once    sync.Once      // protects err, done
+	cause   error          // cause recorded by abort, reported in place of the read loop error
+	cmu     sync.Mutex     // protects cause
+}
+
+// newInbox returns a new inbox queueing up to size messages (DefaultInboundBuffer
+// if not positive), handling any further messages according to policy
+func newInbox(size int, policy OverflowPolicy) *inbox {
+	if size <= 0 {
+		size = DefaultInboundBuffer
+	}
+	return &inbox{
+		msgs:   make(chan []byte, size),
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+}
+
+// push queues an inbound message. If the inbox is full it is handled according
+// to the overflow policy, by default blocking until there is room or ctx is done
+func (in *inbox) push(ctx context.Context, msg []byte) error {
+	switch in.policy {
+	// Discard oldest queued messages until there is room
+	case OverflowDropOldest:
+		for {
+			select {
+			case in.msgs <- msg:
+				return nil
+			default:
+			}
+			select {
+			case <-in.msgs:
+				atomic.AddUint64(&in.dropped, 1)
+			default:
+			}
+		}
+
+	// Discard this message if no room
+	case OverflowDropNewest:
+		select {
+		case in.msgs <- msg:
+		default:
+			atomic.AddUint64(&in.dropped, 1)
+		}
+		return nil
+
+	// Fail the conn if no room
+	case OverflowFail:
+		select {
+		case in.msgs <- msg:
+			return nil
+		default:
+			atomic.AddUint64(&in.dropped, 1)
+			return ErrInboundOverflow
+		}
+
+	// Block until there is room
+	default:
+		select {
+		case in.msgs <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// droppedCount returns the number of inbound messages dropped on overflow
+func (in *inbox) droppedCount() uint64 {
+	return atomic.LoadUint64(&in.dropped)
+}
+
+// pop returns the next queued message, else blocks until one is received
+// or ctx is done, returning ctx's error, or the conn's terminal error
+func (in *inbox) pop(ctx context.Context) ([]byte, error) {
+	select {
+	// Next message received
+	case msg := <-in.msgs:
+		return msg, nil
+
+	// Conn failed, deliver any remaining messages first
+	case <-in.done:
+		select {
+		case msg := <-in.msgs:
+			return msg, nil
+		default:
+			return nil, in.err
+		}
+
+	// Caller gave up waiting
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// failWith records the terminal error for the conn, the cause recorded by abort
+// taking precedence over err, and calls fn with it before any waiting readers are
+// woken (or immediately, should the inbox already have failed). Only the first
+// call records the terminal error
+func (in *inbox) failWith(err error, fn func(error)) {
+	first := false
+	in.once.Do(func() {
+		first = true
+		in.cmu.Lock()
+		if in.cause != nil {
+			err = in.cause
+		}
+		in.cmu.Unlock()
+		in.err = err
+		fn(err)
+		close(in.done)
+	})
+	if !first {
+		fn(in.err)
+	}
+}
+
+// abort records err as the cause of the conn failing, to be reported by failWith
+// in place of the error the read loop ends with once the conn is closed. Only
+// the first call has any effect
+func (in *inbox) abort(err error) {
+	in.cmu.Lock()
+	if in.cause == nil {
+		in.cause = err
+	}
+	in.cmu.Unlock()
+}
@@ -0,0 +1,86 @@
+// Package layer implements the lifecycle shared by the protocol clients layered over
+// a sockjs Client, i.e. the stomp, eventbus, ddp, multiplex, rpc and router packages
+package layer
+
+import (
+	"sync"
+
+	"github.com/rodneyVW/go-sockjsclient"
+)
+
+// closedChan is returned by Done should no read loop have been started
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Lifecycle tracks the read loop of a layered client, started anew on each connect,
+// for reporting via the client's Done and Err. The zero value is ready for use
+type Lifecycle struct {
+	run  *Run       // current read loop, nil until started
+	hook sync.Once  // adds event hook once
+	mu   sync.Mutex // protects run
+}
+
+// Hook adds fn as an event hook of client (see sockjsclient.Client.AddEventHook),
+// only the first call has any effect
+func (l *Lifecycle) Hook(client *sockjsclient.Client, fn func(sockjsclient.Event)) {
+	l.hook.Do(func() { client.AddEventHook(fn) })
+}
+
+// Start starts tracking a new read loop in place of any previous, which must call
+// Stop on exit
+func (l *Lifecycle) Start() *Run {
+	run := &Run{done: make(chan struct{})}
+	l.mu.Lock()
+	l.run = run
+	l.mu.Unlock()
+	return run
+}
+
+// Done returns a channel that is closed once the current read loop exits. If none
+// was started a closed channel is returned
+func (l *Lifecycle) Done() <-chan struct{} {
+	l.mu.Lock()
+	run := l.run
+	l.mu.Unlock()
+	if run == nil {
+		return closedChan
+	}
+	return run.done
+}
+
+// Err returns the error the current read loop exited with, nil until Done is closed.
+// If none was started notConnected is returned
+func (l *Lifecycle) Err(notConnected error) error {
+	l.mu.Lock()
+	run := l.run
+	l.mu.Unlock()
+	if run == nil {
+		return notConnected
+	}
+	select {
+	case <-run.done:
+		return run.err
+	default:
+		return nil
+	}
+}
+
+// Run is a single read loop tracked by a Lifecycle
+type Run struct {
+	done chan struct{} // closed on read loop exit
+	err  error         // read loop exit error, set before done closed
+}
+
+// Done returns a channel that is closed once the read loop exits
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+// Stop records the read loop as exited with err. It must be called exactly once
+func (r *Run) Stop(err error) {
+	r.err = err
+	close(r.done)
+}
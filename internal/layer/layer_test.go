@@ -0,0 +1,46 @@
+package layer
+
+import (
+	"errors"
+	"testing"
+)
+
+var (
+	errTestNotConnected = errors.New("test not connected")
+	errTestStop         = errors.New("test stop")
+)
+
+func TestLifecycle(t *testing.T) {
+	var l Lifecycle
+
+	// Done before started
+	<-l.Done()
+	if err := l.Err(errTestNotConnected); err != errTestNotConnected {
+		t.Fatalf("expected not connected error before start, got %v", err)
+	}
+
+	// Running
+	first := l.Start()
+	select {
+	case <-l.Done():
+		t.Fatal("expected running loop not done")
+	default:
+	}
+	if err := l.Err(errTestNotConnected); err != nil {
+		t.Fatalf("expected no error while running, got %v", err)
+	}
+
+	// Previous loop's exit not seen once started anew
+	second := l.Start()
+	first.Stop(errTestStop)
+	if err := l.Err(errTestNotConnected); err != nil {
+		t.Fatalf("expected previous loop's error unseen, got %v", err)
+	}
+
+	// Stopped
+	second.Stop(errTestStop)
+	<-l.Done()
+	if err := l.Err(errTestNotConnected); err != errTestStop {
+		t.Fatalf("expected stop error once stopped, got %v", err)
+	}
+}
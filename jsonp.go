@@ -0,0 +1,333 @@
+package sockjsclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type JSONPDialer struct {
+	// HTTPClient is the underlying http.Client used by
+	// the produced JSONP conn
+	HTTPClient *http.Client
+
+	// ConnOptions are the options common to all transports
+	ConnOptions
+}
+
+func (d *JSONPDialer) Dial(addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error) {
+	return d.DialContext(context.Background(), addr, serverID, sessionID, hdrs)
+}
+
+func (d *JSONPDialer) DialContext(ctx context.Context, addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error) {
+	// Parse a valid transport address
+	taddr, err := parseTransportAddr(addr, serverID, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Ensure an HTTP client is set
+	if d.HTTPClient == nil {
+		d.HTTPClient = http.DefaultClient
+	}
+
+	// Prepare connection endpoints
+	callback := newCallbackName()
+	readAddr := taddr + "/jsonp?c=" + url.QueryEscape(callback)
+	writeAddr := taddr + "/jsonp_send"
+
+	// Attempt opening connection
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		readAddr,
+		nil,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range hdrs {
+		req.Header[key] = values
+	}
+
+	// Send initial request
+	rsp, err := d.HTTPClient.Do(req)
+	if rsp != nil {
+		defer rsp.Body.Close()
+	}
+	if err != nil {
+		return nil, rsp, err
+	}
+
+	// Read and validate initial message
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, rsp, err
+	} else if b, err = unwrapCallback(b, callback); err != nil {
+		return nil, rsp, err
+	} else if mt, _, err := parseMessage(b); err != nil || mt != MessageTypeOpen {
+		return nil, rsp, fmt.Errorf("%w: opening sockjs session", ErrInvalidResponse)
+	}
+
+	// Create new connection with cancel context
+	ctx, cncl := context.WithCancel(context.Background())
+	conn := &jsonpConn{
+		client:    *d.HTTPClient,
+		raddr:     readAddr,
+		waddr:     writeAddr,
+		callback:  callback,
+		hdrs:      hdrs,
+		cncl:      cncl,
+		in:        newInbox(d.InboundBuffer, d.Overflow),
+		events:    d.events,
+		heartbeat: d.HeartbeatTimeout,
+		ctx:       ctx,
+	}
+	go conn.run()
+
+	return conn, rsp, nil
+}
+
+// jsonpConn represents a sockjs JSONP polling client connection,
+// handling data passing, script unwrapping and error tracking
+type jsonpConn struct {
+	client    http.Client     // our provided HTTP client
+	raddr     string          // prepared JSONP read endpoint addr
+	waddr     string          // prepared JSONP write endpoint addr
+	callback  string          // callback name frames are wrapped in
+	hdrs      http.Header     // headers to provide on each request
+	cncl      func()          // context cancel
+	in        *inbox          // inbound message queue
+	ctx       context.Context // Conn context
+	events    eventFunc       // lifecycle event hook
+	heartbeat time.Duration   // heartbeat timeout
+}
+
+// run starts the read loop and handles final error propagation
+func (conn *jsonpConn) run() {
+	// Start the read loop
+	err := conn.readLoop()
+	if err == nil {
+		panic("closed read loop with nil error")
+	}
+
+	// Propagate error (unless already failed), emitting disconnect before readers see it
+	conn.in.failWith(maskCtxCancelled(conn.ctx, err), func(err error) {
+		conn.events.emit(Event{Type: EventDisconnect, Err: err})
+	})
+}
+
+// readLoop is the main jsonp read routine, handling passing
+// of inbound messages ready to be received
+func (conn *jsonpConn) readLoop() error {
+	const defaultTimeout = time.Second * 30
+
+	// Get our own copy with biggest set timeout
+	client := conn.client
+	if client.Timeout < defaultTimeout {
+		client.Timeout = defaultTimeout
+	}
+
+	// ensure closed
+	defer conn.Close()
+
+	// Watch for missed heartbeats
+	watchdog := newWatchdog(conn.heartbeat, func() {
+		conn.in.abort(ErrNoHeartbeat)
+		conn.Close() // kill conn
+	})
+	defer watchdog.stop()
+
+	for {
+		// Prepare read request (addr is constant, but checks ctx status)
+		req, err := http.NewRequestWithContext(conn.ctx, "GET", conn.raddr, http.NoBody)
+		if err != nil {
+			return err
+		}
+		for key, values := range conn.hdrs {
+			req.Header[key] = values
+		}
+
+		// Perform next read request
+		rsp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		switch rsp.StatusCode {
+		// Success!
+		case 200:
+
+		// i.e. session not found --> closed
+		case 404:
+			rsp.Body.Close()
+			return fmt.Errorf("%w (no close frame received)", ErrClosedConnection)
+
+		// Unexpected status code
+		default:
+			rsp.Body.Close()
+			return fmt.Errorf("%w (HTTP %d)", ErrUnexpectedResponse, rsp.StatusCode)
+		}
+
+		// Read response body and close
+		b, err := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		// Extract frame from script
+		b, err = unwrapCallback(b, conn.callback)
+		if err != nil {
+			return err
+		}
+
+		// Parse message type
+		watchdog.feed()
+		conn.events.frame(b)
+		mt, b, err := parseMessage(b)
+		if err != nil {
+			return err
+		}
+
+		switch mt {
+		// Parse message block, pass along
+		case MessageTypeData:
+			msgs, err := unmarshalMessages(b)
+			if err != nil {
+				return err
+			}
+			for _, msg := range msgs {
+				if err := conn.in.push(conn.ctx, []byte(msg)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// ReadMsg implements Conn.ReadMsg()
+func (conn *jsonpConn) ReadMsg() ([]byte, error) {
+	return conn.in.pop(context.Background())
+}
+
+// ReadMsgContext implements Conn.ReadMsgContext()
+func (conn *jsonpConn) ReadMsgContext(ctx context.Context) ([]byte, error) {
+	return conn.in.pop(ctx)
+}
+
+// WriteMsg implements Conn.WriteMsg()
+func (conn *jsonpConn) WriteMsg(data ...[]byte) error {
+	return conn.WriteMsgContext(context.Background(), data...)
+}
+
+// WriteMsgContext implements Conn.WriteMsgContext()
+func (conn *jsonpConn) WriteMsgContext(ctx context.Context, data ...[]byte) error {
+	// Check if already closed
+	if conn.ctx.Err() != nil {
+		return ErrClosedConnection
+	}
+
+	// Convert to message block
+	b, err := marshalMessages(data)
+	if err != nil {
+		return err
+	}
+
+	// Prepare write context, also checking conn status
+	wctx, cncl := joinContext(conn.ctx, ctx)
+	defer cncl()
+
+	// Perform the write request
+	if err := conn.send(wctx, b); err != nil {
+		// Check for cancelled write, conn stays open
+		if ctx.Err() != nil && conn.ctx.Err() == nil {
+			return ctx.Err()
+		}
+		conn.cncl() // ensure closed
+		return err
+	}
+
+	return nil
+}
+
+// send posts a sockjs message block form-encoded to the JSONP write endpoint
+func (conn *jsonpConn) send(ctx context.Context, b []byte) error {
+	// Prepare new write request (addr is constant, but checks ctx status)
+	body := url.Values{"d": []string{string(b)}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, "POST", conn.waddr, strings.NewReader(body))
+	if err != nil {
+		return maskCtxCancelled(ctx, err)
+	}
+	for key, values := range conn.hdrs {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Perform the write request
+	rsp, err := conn.client.Do(req)
+	if err != nil {
+		return maskCtxCancelled(ctx, err)
+	}
+	defer rsp.Body.Close()
+
+	switch rsp.StatusCode {
+	// Success!
+	case 200, 204:
+		return nil
+
+	// i.e. session not found --> closed
+	case 404:
+		return ErrClosedConnection
+
+	// Unexpected status code
+	default:
+		return fmt.Errorf("%w (HTTP %d)", ErrUnexpectedResponse, rsp.StatusCode)
+	}
+}
+
+// Close implements Conn.Close()
+func (conn *jsonpConn) Close() error {
+	conn.cncl()
+	return nil
+}
+
+// Stats implements Conn.Stats(), with no websocket ping statistics available
+func (conn *jsonpConn) Stats() Stats {
+	return Stats{MessagesDropped: conn.in.droppedCount()}
+}
+
+// GetConnection implements Conn.GetConnection(), always nil as there is no websocket
+func (conn *jsonpConn) GetConnection() *websocket.Conn {
+	return nil
+}
+
+// unwrapCallback extracts the sockjs frame from a script calling the
+// named callback with a single JSON string argument, e.g. `cb("o");`
+func unwrapCallback(script []byte, callback string) ([]byte, error) {
+	// Trim whitespace and any leading comment
+	script = bytes.TrimSpace(script)
+	script = bytes.TrimPrefix(script, []byte("/**/"))
+
+	// Check this is a call to callback
+	if !bytes.HasPrefix(script, []byte(callback+"(")) || !bytes.HasSuffix(script, []byte(");")) {
+		return nil, fmt.Errorf("%w: unexpected script wrapping frame", ErrInvalidResponse)
+	}
+	script = script[len(callback)+1 : len(script)-2]
+
+	// Decode the frame string argument
+	var frame string
+	if err := json.Unmarshal(script, &frame); err != nil {
+		return nil, fmt.Errorf("%w: decoding script frame: %v", ErrInvalidResponse, err)
+	}
+
+	return []byte(frame), nil
+}
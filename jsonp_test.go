@@ -0,0 +1,45 @@
+package sockjsclient
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestUnwrapCallback(t *testing.T) {
+	for _, tc := range []struct {
+		script string
+		frame  string
+		err    error
+	}{
+		{script: "/**/cb(\"o\");\r\n", frame: "o"},
+		{script: `cb("a[\"x\\\"y\"]");`, frame: `a["x\"y"]`},
+		{script: `other("o");`, err: ErrInvalidResponse},
+		{script: `cb("o")`, err: ErrInvalidResponse},
+		{script: `cb(o);`, err: ErrInvalidResponse},
+	} {
+		frame, err := unwrapCallback([]byte(tc.script), "cb")
+		if string(frame) != tc.frame || !errors.Is(err, tc.err) {
+			t.Fatalf("unwrapped script %q was not as expected: {Expect=%q,%v Got=%q,%v}", tc.script, tc.frame, tc.err, frame, err)
+		}
+	}
+}
+
+func TestReadHTMLFileFrame(t *testing.T) {
+	page := "<!doctype html>\n<html><body>\n  <script>\n    var c = parent.cb;\n    function p(d) {c.message(d);};\n  </script>\n" +
+		strings.Repeat(" ", 512) + "\r\n\r\n<script>\np(\"o\");\n</script>\r\n<script>\np(\"a[\\\"x\\\"]\");\n</script>\r\n"
+	r := bufio.NewReader(strings.NewReader(page))
+	for _, exp := range []string{"o", `a["x"]`} {
+		frame, err := readHTMLFileFrame(r)
+		if err != nil {
+			t.Fatalf("error reading frame %q: %v", exp, err)
+		} else if string(frame) != exp {
+			t.Fatalf("frame was not as expected: {Expect=%q Frame=%q}", exp, frame)
+		}
+	}
+	if _, err := readHTMLFileFrame(r); err != io.EOF {
+		t.Fatalf("expected EOF at end of stream, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package multiplex
+
+import (
+	"context"
+	"sync"
+)
+
+// Channel is a logical channel sharing a Multiplexer's sockjs session, exchanging
+// messages under its topic. It is used much like a sockjsclient.Conn
+type Channel struct {
+	Topic string
+
+	mx   *Multiplexer  // owning multiplexer
+	msgs chan []byte   // queued inbound messages
+	done chan struct{} // closed on channel close
+	err  error         // terminal channel error
+	once sync.Once     // protects done, err
+}
+
+// fail closes the channel with err, only the first call has any effect
+func (ch *Channel) fail(err error) {
+	ch.once.Do(func() {
+		ch.err = err
+		close(ch.done)
+	})
+}
+
+// ReadMsg reads the next message received on this channel
+func (ch *Channel) ReadMsg() ([]byte, error) {
+	return ch.ReadMsgContext(context.Background())
+}
+
+// ReadMsgContext reads the next message received on this channel, giving up
+// with ctx's error should it be done first. The channel stays open
+func (ch *Channel) ReadMsgContext(ctx context.Context) ([]byte, error) {
+	select {
+	// Next message received
+	case msg := <-ch.msgs:
+		return msg, nil
+
+	// Channel closed, deliver any remaining messages first
+	case <-ch.done:
+		select {
+		case msg := <-ch.msgs:
+			return msg, nil
+		default:
+			return nil, ch.err
+		}
+
+	// Caller gave up waiting
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WriteMsg writes a message to this channel
+func (ch *Channel) WriteMsg(msg []byte) error {
+	return ch.WriteMsgContext(context.Background(), msg)
+}
+
+// WriteMsgContext writes a message to this channel, abandoning the
+// write with ctx's error should it be done first
+func (ch *Channel) WriteMsgContext(ctx context.Context, msg []byte) error {
+	select {
+	case <-ch.done:
+		return ErrChannelClosed
+	default:
+	}
+	return ch.mx.SockJS.WriteMsgContext(ctx, formatFrame(typeMsg, ch.Topic, msg))
+}
+
+// Done returns a channel that is closed once this channel is closed
+func (ch *Channel) Done() <-chan struct{} {
+	return ch.done
+}
+
+// Close unsubscribes from this channel's topic. Further reads return
+// any remaining queued messages, then ErrChannelClosed
+func (ch *Channel) Close() error {
+	ch.fail(ErrChannelClosed)
+	if !ch.mx.remove(ch) {
+		return nil // already closed
+	}
+	return ch.mx.SockJS.WriteMsg(formatFrame(typeUns, ch.Topic, nil))
+}
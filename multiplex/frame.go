@@ -0,0 +1,34 @@
+package multiplex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// websocket-multiplex frame types
+const (
+	typeSub = "sub"
+	typeMsg = "msg"
+	typeUns = "uns"
+)
+
+// formatFrame returns a frame of typ for topic, with payload for msg frames
+func formatFrame(typ, topic string, payload []byte) []byte {
+	if typ != typeMsg {
+		return []byte(typ + "," + topic)
+	}
+	return []byte(typ + "," + topic + "," + string(payload))
+}
+
+// parseFrame parses the type, topic and payload (msg frames only) of a frame
+func parseFrame(b []byte) (string, string, []byte, error) {
+	parts := strings.SplitN(string(b), ",", 3)
+	switch {
+	case len(parts) == 3 && parts[0] == typeMsg:
+		return parts[0], parts[1], []byte(parts[2]), nil
+	case len(parts) == 2 && (parts[0] == typeSub || parts[0] == typeUns):
+		return parts[0], parts[1], nil, nil
+	default:
+		return "", "", nil, fmt.Errorf("%w: %.32q", ErrInvalidFrame, b)
+	}
+}
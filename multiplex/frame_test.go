@@ -0,0 +1,41 @@
+package multiplex
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormatFrame(t *testing.T) {
+	for _, tc := range []struct {
+		typ, topic, payload string
+		exp                 string
+	}{
+		{typ: typeSub, topic: "ann", exp: "sub,ann"},
+		{typ: typeMsg, topic: "ann", payload: "a,b,c", exp: "msg,ann,a,b,c"},
+		{typ: typeUns, topic: "ann", exp: "uns,ann"},
+	} {
+		if got := string(formatFrame(tc.typ, tc.topic, []byte(tc.payload))); got != tc.exp {
+			t.Fatalf("formatted frame was not as expected: {Expect=%q Got=%q}", tc.exp, got)
+		}
+	}
+}
+
+func TestParseFrame(t *testing.T) {
+	typ, topic, payload, err := parseFrame([]byte("msg,bob,hello, world"))
+	if err != nil {
+		t.Fatalf("error parsing frame: %v", err)
+	} else if typ != typeMsg || topic != "bob" || string(payload) != "hello, world" {
+		t.Fatalf("parsed frame was not as expected: %q %q %q", typ, topic, payload)
+	}
+
+	typ, topic, _, err = parseFrame([]byte("uns,bob"))
+	if err != nil || typ != typeUns || topic != "bob" {
+		t.Fatalf("parsed uns frame was not as expected: %q %q (err=%v)", typ, topic, err)
+	}
+
+	for _, data := range []string{"msg,bob", "uns", "pub,bob,x", ""} {
+		if _, _, _, err := parseFrame([]byte(data)); !errors.Is(err, ErrInvalidFrame) {
+			t.Fatalf("expected ErrInvalidFrame parsing %q, got %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package multiplex implements the sockjs websocket-multiplex convention over a sockjs
+// Client, sharing one session between many logical channels each identified by a topic
+package multiplex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/internal/layer"
+)
+
+// DefaultChannelBuffer is the number of inbound messages a channel queues for
+// reading when no ChannelBuffer is configured
+const DefaultChannelBuffer = 10
+
+// Multiplex error messages
+var (
+	ErrNotConnected   = errors.New("multiplex: not connected")
+	ErrInvalidFrame   = errors.New("multiplex: invalid frame")
+	ErrInvalidTopic   = errors.New("multiplex: invalid topic")
+	ErrTopicInUse     = errors.New("multiplex: topic already subscribed")
+	ErrChannelClosed  = errors.New("multiplex: use of a closed channel")
+	ErrClosedByRemote = errors.New("multiplex: channel closed by remote")
+)
+
+// Multiplexer shares a sockjs Client between channels, subscribing each channel's topic
+// again whenever the sockjs client reconnects (see sockjsclient.Client.Reconnect)
+type Multiplexer struct {
+	// SockJS is the sockjs client whose session the channels share, configured but not yet
+	// connected. Connect adds an event hook to it, to subscribe channels again on reconnect
+	SockJS *sockjsclient.Client
+
+	// ChannelBuffer is the number of inbound messages each channel queues for
+	// reading. Zero means DefaultChannelBuffer. Should a channel's buffer be full,
+	// all channels stall until it is read from
+	ChannelBuffer int
+
+	channels map[string]*Channel // open channels by topic
+	life     context.Context     // session lifetime context, cancelled on close
+	cncl     func()              // session lifetime context cancel
+	loop     layer.Lifecycle     // demux lifecycle
+	mu       sync.Mutex          // protects channels, life
+}
+
+func (mx *Multiplexer) Connect() error {
+	return mx.ConnectContext(context.Background())
+}
+
+// ConnectContext connects the sockjs client, giving up with ctx's error should it be done first
+func (mx *Multiplexer) ConnectContext(ctx context.Context) error {
+	// Subscribe channels again on each reconnect
+	mx.loop.Hook(mx.SockJS, func(ev sockjsclient.Event) {
+		if ev.Type == sockjsclient.EventReconnected {
+			go mx.resubscribe()
+		}
+	})
+
+	// Connect underlying sockjs client
+	if err := mx.SockJS.ConnectContext(ctx); err != nil {
+		return err
+	}
+
+	// Set up new session
+	mx.mu.Lock()
+	if mx.cncl != nil {
+		mx.cncl()
+	}
+	mx.channels = map[string]*Channel{}
+	mx.life, mx.cncl = context.WithCancel(context.Background())
+	life, run := mx.life, mx.loop.Start()
+	mx.mu.Unlock()
+
+	go mx.demux(life, run)
+
+	return nil
+}
+
+// demux routes inbound frames to their channels until the sockjs client fails or is closed
+func (mx *Multiplexer) demux(life context.Context, run *layer.Run) {
+	err := mx.route(life)
+
+	// Fail all open channels, no more can be opened
+	mx.mu.Lock()
+	channels := mx.channels
+	mx.channels = nil
+	mx.mu.Unlock()
+	run.Stop(err)
+	for _, ch := range channels {
+		ch.fail(err)
+	}
+}
+
+// route reads frames, passing each to its channel, until an error occurs. Frames not
+// following the websocket-multiplex convention are skipped
+func (mx *Multiplexer) route(life context.Context) error {
+	for {
+		b, err := mx.SockJS.ReadMsg()
+		if err != nil {
+			return err
+		}
+		typ, topic, payload, err := parseFrame(b)
+		if err != nil {
+			continue // not multiplexed, so for no channel
+		}
+
+		mx.mu.Lock()
+		ch := mx.channels[topic]
+		if ch != nil && typ == typeUns {
+			delete(mx.channels, topic)
+		}
+		mx.mu.Unlock()
+		if ch == nil {
+			continue // unknown, or since closed
+		}
+
+		switch typ {
+		// Queue for channel reader
+		case typeMsg:
+			select {
+			case ch.msgs <- payload:
+			case <-ch.done:
+			case <-life.Done():
+				return sockjsclient.ErrClosedConnection
+			}
+
+		// Closed by remote
+		case typeUns:
+			ch.fail(ErrClosedByRemote)
+		}
+	}
+}
+
+// resubscribe sends a sub frame for each open channel
+func (mx *Multiplexer) resubscribe() {
+	mx.mu.Lock()
+	topics := make([]string, 0, len(mx.channels))
+	for topic := range mx.channels {
+		topics = append(topics, topic)
+	}
+	mx.mu.Unlock()
+
+	for _, topic := range topics {
+		mx.SockJS.WriteMsg(formatFrame(typeSub, topic, nil)) // failure is seen by demux
+	}
+}
+
+// Channel subscribes to topic, returning a channel to exchange messages on
+func (mx *Multiplexer) Channel(topic string) (*Channel, error) {
+	return mx.ChannelContext(context.Background(), topic)
+}
+
+// ChannelContext is as Channel, but abandons subscribing with ctx's error should it be done first
+func (mx *Multiplexer) ChannelContext(ctx context.Context, topic string) (*Channel, error) {
+	if topic == "" || strings.Contains(topic, ",") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTopic, topic)
+	}
+
+	size := mx.ChannelBuffer
+	if size <= 0 {
+		size = DefaultChannelBuffer
+	}
+	ch := &Channel{
+		Topic: topic,
+		mx:    mx,
+		msgs:  make(chan []byte, size),
+		done:  make(chan struct{}),
+	}
+
+	// Register before subscribing, to catch first messages
+	mx.mu.Lock()
+	if mx.channels == nil {
+		mx.mu.Unlock()
+		return nil, ErrNotConnected
+	} else if _, ok := mx.channels[topic]; ok {
+		mx.mu.Unlock()
+		return nil, fmt.Errorf("%w: %q", ErrTopicInUse, topic)
+	}
+	mx.channels[topic] = ch
+	mx.mu.Unlock()
+
+	if err := mx.SockJS.WriteMsgContext(ctx, formatFrame(typeSub, topic, nil)); err != nil {
+		mx.remove(ch)
+		return nil, err
+	}
+
+	return ch, nil
+}
+
+// remove removes ch from open channels, returning whether it was open
+func (mx *Multiplexer) remove(ch *Channel) bool {
+	mx.mu.Lock()
+	defer mx.mu.Unlock()
+	if mx.channels[ch.Topic] != ch {
+		return false
+	}
+	delete(mx.channels, ch.Topic)
+	return true
+}
+
+// Done returns a channel that is closed once the multiplexer stops routing
+// messages, i.e. the sockjs client was closed or lost for good
+func (mx *Multiplexer) Done() <-chan struct{} {
+	return mx.loop.Done()
+}
+
+// Err returns the error that stopped the multiplexer routing messages, nil until Done
+// is closed, or ErrNotConnected if never connected
+func (mx *Multiplexer) Err() error {
+	return mx.loop.Err(ErrNotConnected)
+}
+
+// Close closes the sockjs client, and with it all channels
+func (mx *Multiplexer) Close() error {
+	mx.mu.Lock()
+	if mx.cncl != nil {
+		mx.cncl() // stop any blocked routing
+	}
+	mx.mu.Unlock()
+	return mx.SockJS.Close()
+}
@@ -0,0 +1,149 @@
+package multiplex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestMultiplexerDemux(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	mx, session := connectTestMultiplexer(t, srv, nil)
+	defer mx.Close()
+
+	ann := openTestChannel(t, mx, session, "ann")
+	bob := openTestChannel(t, mx, session, "bob")
+
+	// Routed by topic, skipping unknown topics and frames not multiplexed
+	session.Send("msg,carl,lost", "not multiplexed", "msg,bob,to bob", "msg,ann,to ann, again")
+	for _, tc := range []struct {
+		ch  *Channel
+		exp string
+	}{
+		{ch: ann, exp: "to ann, again"},
+		{ch: bob, exp: "to bob"},
+	} {
+		if msg, err := tc.ch.ReadMsgContext(testContext(t)); err != nil {
+			t.Fatalf("error reading %s channel: %v", tc.ch.Topic, err)
+		} else if string(msg) != tc.exp {
+			t.Fatalf("%s channel message was not as expected: {Expect=%q Message=%q}", tc.ch.Topic, tc.exp, msg)
+		}
+	}
+
+	// Written under topic
+	if err := bob.WriteMsg([]byte("from bob")); err != nil {
+		t.Fatalf("error writing bob channel: %v", err)
+	}
+	if got, err := session.Recv(testContext(t)); err != nil || got != "msg,bob,from bob" {
+		t.Fatalf("written frame was not as expected: %q (err=%v)", got, err)
+	}
+}
+
+func TestMultiplexerChannelClose(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	mx, session := connectTestMultiplexer(t, srv, nil)
+	defer mx.Close()
+
+	ann := openTestChannel(t, mx, session, "ann")
+	if _, err := mx.Channel("ann"); !errors.Is(err, ErrTopicInUse) {
+		t.Fatalf("expected ErrTopicInUse, got %v", err)
+	}
+
+	// Closed by remote, queued messages read first
+	session.Send("msg,ann,last", "uns,ann")
+	if msg, err := ann.ReadMsgContext(testContext(t)); err != nil || string(msg) != "last" {
+		t.Fatalf("expected queued message before close, got %q (err=%v)", msg, err)
+	}
+	if _, err := ann.ReadMsgContext(testContext(t)); !errors.Is(err, ErrClosedByRemote) {
+		t.Fatalf("expected ErrClosedByRemote, got %v", err)
+	}
+
+	// Closed locally, unsubscribing
+	bob := openTestChannel(t, mx, session, "bob")
+	if err := bob.Close(); err != nil {
+		t.Fatalf("error closing bob channel: %v", err)
+	}
+	if got, err := session.Recv(testContext(t)); err != nil || got != "uns,bob" {
+		t.Fatalf("unsubscribe frame was not as expected: %q (err=%v)", got, err)
+	} else if err := bob.WriteMsg([]byte("x")); !errors.Is(err, ErrChannelClosed) {
+		t.Fatalf("expected ErrChannelClosed writing closed channel, got %v", err)
+	}
+
+	// All channels failed once the session is lost
+	carl := openTestChannel(t, mx, session, "carl")
+	session.Close(sockjsclient.CloseGoAway, "Go away!")
+	<-mx.Done()
+	if _, err := carl.ReadMsgContext(testContext(t)); !sockjsclient.IsGoAway(err) {
+		t.Fatalf("expected go away close error reading channel, got %v", err)
+	} else if err := mx.Err(); !sockjsclient.IsGoAway(err) {
+		t.Fatalf("expected go away close error, got %v", err)
+	}
+}
+
+func TestMultiplexerResubscribe(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	mx, session := connectTestMultiplexer(t, srv, &sockjsclient.ReconnectPolicy{InitialDelay: time.Millisecond})
+	defer mx.Close()
+
+	ann := openTestChannel(t, mx, session, "ann")
+
+	// Subscribed again once reconnected, carrying on routing
+	session.Close(1002, "Connection interrupted")
+	resumed, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting reconnected session: %v", err)
+	}
+	if got, err := resumed.Recv(testContext(t)); err != nil || got != "sub,ann" {
+		t.Fatalf("resubscribe frame was not as expected: %q (err=%v)", got, err)
+	}
+	resumed.Send("msg,ann,again")
+	if msg, err := ann.ReadMsgContext(testContext(t)); err != nil || string(msg) != "again" {
+		t.Fatalf("expected message after reconnect, got %q (err=%v)", msg, err)
+	}
+}
+
+// connectTestMultiplexer connects a multiplexer to srv, reconnecting according to
+// reconnect if set, returning it with its accepted session
+func connectTestMultiplexer(t *testing.T, srv *sockjstest.Server, reconnect *sockjsclient.ReconnectPolicy) (*Multiplexer, *sockjstest.Session) {
+	mx := &Multiplexer{
+		SockJS: &sockjsclient.Client{
+			Address:    srv.URL,
+			Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+			Reconnect:  reconnect,
+		},
+	}
+	if err := mx.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting multiplexer: %v", err)
+	}
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+	return mx, session
+}
+
+// openTestChannel opens a channel for topic, checking its subscription is received over session
+func openTestChannel(t *testing.T, mx *Multiplexer, session *sockjstest.Session, topic string) *Channel {
+	ch, err := mx.ChannelContext(testContext(t), topic)
+	if err != nil {
+		t.Fatalf("error opening %s channel: %v", topic, err)
+	}
+	if got, err := session.Recv(testContext(t)); err != nil || got != "sub,"+topic {
+		t.Fatalf("subscribe frame was not as expected: %q (err=%v)", got, err)
+	}
+	return ch
+}
+
+// testContext returns a context bounding a single test step
+func testContext(t *testing.T) context.Context {
+	ctx, cncl := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cncl)
+	return ctx
+}
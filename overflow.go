@@ -0,0 +1,48 @@
+package sockjsclient
+
+// DefaultInboundBuffer is the number of inbound messages a conn queues for
+// reading when no InboundBuffer is configured
+const DefaultInboundBuffer = 10
+
+// OverflowPolicy determines what a conn does with an inbound message received
+// while its inbound buffer is full, i.e. the application is not reading quickly enough
+type OverflowPolicy uint8
+
+// Inbound overflow policies
+const (
+	// OverflowDefault is the zero policy, a dialer's OverflowDefault takes the Client's
+	// policy, while a conn with OverflowDefault behaves as OverflowBlock
+	OverflowDefault = OverflowPolicy(iota)
+
+	// OverflowBlock stops reading from the server until there is room in the
+	// buffer, applying backpressure. No frames (including heartbeats) are
+	// processed in the meantime, so a stalled reader may cause ErrNoHeartbeat
+	OverflowBlock
+
+	// OverflowDropOldest discards the oldest buffered message to make room
+	OverflowDropOldest
+
+	// OverflowDropNewest discards the received message
+	OverflowDropNewest
+
+	// OverflowFail fails the conn with ErrInboundOverflow
+	OverflowFail
+)
+
+// String returns a string representation of overflow policy
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDefault:
+		return "default"
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowFail:
+		return "fail"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,80 @@
+package sockjsclient_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestClientOverflow(t *testing.T) {
+	for _, tc := range []struct {
+		client  sockjsclient.OverflowPolicy // Client policy
+		dialer  sockjsclient.OverflowPolicy // dialer policy, taking precedence unless default
+		expect  []string                    // messages read, in order
+		dropped uint64                      // expected dropped count
+		fail    bool                        // conn expected to fail with ErrInboundOverflow
+	}{
+		{client: sockjsclient.OverflowDefault, expect: []string{"1", "2", "3", "4", "5"}},
+		{client: sockjsclient.OverflowBlock, expect: []string{"1", "2", "3", "4", "5"}},
+		{client: sockjsclient.OverflowDropOldest, expect: []string{"4", "5"}, dropped: 3},
+		{client: sockjsclient.OverflowDropNewest, expect: []string{"1", "2"}, dropped: 3},
+		{client: sockjsclient.OverflowFail, expect: []string{"1", "2"}, dropped: 1, fail: true},
+		{client: sockjsclient.OverflowDropNewest, dialer: sockjsclient.OverflowBlock, expect: []string{"1", "2", "3", "4", "5"}},
+		{client: sockjsclient.OverflowBlock, dialer: sockjsclient.OverflowDropOldest, expect: []string{"4", "5"}, dropped: 3},
+	} {
+		t.Run(tc.client.String()+"/"+tc.dialer.String(), func(t *testing.T) {
+			srv := sockjstest.NewServer()
+			defer srv.Close()
+
+			client := &sockjsclient.Client{
+				Address:       srv.URL,
+				Transports:    []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+				InboundBuffer: 2,
+				Overflow:      tc.client,
+				WSDialer: &sockjsclient.WSDialer{
+					ConnOptions: sockjsclient.ConnOptions{Overflow: tc.dialer},
+				},
+			}
+			if err := client.ConnectContext(testContext(t)); err != nil {
+				t.Fatalf("error connecting to sockjs test server: %v", err)
+			}
+			defer client.Close()
+			session, err := srv.Accept(testContext(t))
+			if err != nil {
+				t.Fatalf("error accepting session: %v", err)
+			}
+
+			// Overflow the buffer with a single frame, waiting for it to be handled
+			session.Send("1", "2", "3", "4", "5")
+			if tc.dropped > 0 {
+				deadline := time.Now().Add(time.Second * 5)
+				for client.Stats().MessagesDropped != tc.dropped {
+					if time.Now().After(deadline) {
+						t.Fatalf("expected %d messages dropped, got %d", tc.dropped, client.Stats().MessagesDropped)
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			for _, exp := range tc.expect {
+				msg, err := client.ReadMsgContext(testContext(t))
+				if err != nil {
+					t.Fatalf("error receiving message %q: %v", exp, err)
+				} else if string(msg) != exp {
+					t.Fatalf("message from server was not as expected: {Expect=%q Message=%q}", exp, msg)
+				}
+			}
+			if tc.fail {
+				if _, err := client.ReadMsgContext(testContext(t)); !errors.Is(err, sockjsclient.ErrInboundOverflow) {
+					t.Fatalf("expected ErrInboundOverflow, got %v", err)
+				}
+			}
+			if dropped := client.Stats().MessagesDropped; dropped != tc.dropped {
+				t.Fatalf("expected %d messages dropped, got %d", tc.dropped, dropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package sockjsclient
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Default reconnect policy delays
+const (
+	DefaultReconnectInitialDelay = time.Second
+	DefaultReconnectMaxDelay     = time.Second * 30
+)
+
+// ReconnectPolicy configures automatic reconnection of a Client, with
+// an exponentially increasing delay between each successive attempt
+type ReconnectPolicy struct {
+	// InitialDelay is the delay before the first reconnect attempt,
+	// doubling with each attempt after. Defaults to DefaultReconnectInitialDelay
+	InitialDelay time.Duration
+
+	// MaxDelay caps the delay between reconnect attempts,
+	// defaults to DefaultReconnectMaxDelay
+	MaxDelay time.Duration
+
+	// Jitter randomizes each delay by up to +/- this fraction of it,
+	// avoiding thundering herds of clients. It ranges from 0 (none) to
+	// 1, values outside this range being clamped to it
+	Jitter float64
+
+	// MaxAttempts limits the number of reconnect attempts made
+	// for each lost connection, zero meaning unlimited
+	MaxAttempts int
+
+	// Retryable decides whether to reconnect given the error the
+	// connection was lost with. If nil, DefaultRetryable is used
+	Retryable func(error) bool
+}
+
+// DefaultRetryable retries all lost connections, except those where the
+// server deliberately ended the session with a "Go away!" close frame
+func DefaultRetryable(err error) bool {
+	return !IsGoAway(err)
+}
+
+// retryable returns whether to reconnect following err
+func (p *ReconnectPolicy) retryable(err error) bool {
+	if p.Retryable == nil {
+		return DefaultRetryable(err)
+	}
+	return p.Retryable(err)
+}
+
+// delay returns the backoff delay before given reconnect attempt (starting at 1)
+func (p *ReconnectPolicy) delay(attempt int) time.Duration {
+	initial := p.InitialDelay
+	if initial <= 0 {
+		initial = DefaultReconnectInitialDelay
+	}
+	max := p.MaxDelay
+	if max <= 0 {
+		max = DefaultReconnectMaxDelay
+	}
+
+	// Double delay for each attempt, up to max
+	d := initial
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	// Apply any jitter, never going negative
+	if jitter := math.Min(p.Jitter, 1); jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * jitter * float64(d))
+	}
+	if d < 0 {
+		d = 0
+	}
+
+	return d
+}
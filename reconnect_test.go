@@ -0,0 +1,59 @@
+package sockjsclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReconnectPolicyDelay(t *testing.T) {
+	p := &ReconnectPolicy{InitialDelay: time.Second, MaxDelay: time.Second * 5}
+	for attempt, exp := range []time.Duration{time.Second, time.Second * 2, time.Second * 4, time.Second * 5, time.Second * 5} {
+		if d := p.delay(attempt + 1); d != exp {
+			t.Fatalf("delay for attempt %d was not as expected: {Expect=%v Delay=%v}", attempt+1, exp, d)
+		}
+	}
+
+	// Defaults
+	p = &ReconnectPolicy{}
+	if d := p.delay(1); d != DefaultReconnectInitialDelay {
+		t.Fatalf("expected default initial delay, got %v", d)
+	} else if d := p.delay(100); d != DefaultReconnectMaxDelay {
+		t.Fatalf("expected default max delay, got %v", d)
+	}
+
+	// Jitter within bounds
+	p = &ReconnectPolicy{InitialDelay: time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if d := p.delay(1); d < time.Second/2 || d > time.Second*3/2 {
+			t.Fatalf("expected jittered delay within bounds, got %v", d)
+		}
+	}
+
+	// Jitter clamped to [0, 1]
+	for _, jitter := range []float64{-1, 5} {
+		p = &ReconnectPolicy{InitialDelay: time.Second, Jitter: jitter}
+		for i := 0; i < 100; i++ {
+			if d := p.delay(1); d < 0 || d > time.Second*2 || (jitter < 0 && d != time.Second) {
+				t.Fatalf("expected jitter %v clamped, got delay %v", jitter, d)
+			}
+		}
+	}
+}
+
+func TestReconnectPolicyRetryable(t *testing.T) {
+	goAway := &CloseError{Code: CloseGoAway, Reason: "Go away!"}
+	interrupted := &CloseError{Code: 1002, Reason: "Connection interrupted"}
+
+	p := &ReconnectPolicy{}
+	if p.retryable(goAway) {
+		t.Fatal("expected go away not retried by default")
+	} else if !p.retryable(interrupted) || !p.retryable(ErrNoHeartbeat) {
+		t.Fatal("expected lost connections retried by default")
+	}
+
+	p.Retryable = func(err error) bool { return errors.Is(err, ErrNoHeartbeat) }
+	if p.retryable(interrupted) || !p.retryable(ErrNoHeartbeat) {
+		t.Fatal("expected custom retryable used")
+	}
+}
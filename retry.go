@@ -0,0 +1,106 @@
+package sockjsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryDelay is the default initial delay between retries of transient XHR failures
+const DefaultRetryDelay = time.Millisecond * 250
+
+// statusError represents an unexpected HTTP response status code,
+// matching ErrUnexpectedResponse with errors.Is
+type statusError struct {
+	code int
+}
+
+// Error implements error
+func (err *statusError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", ErrUnexpectedResponse.Error(), err.code)
+}
+
+// Is returns whether target is ErrUnexpectedResponse
+func (err *statusError) Is(target error) bool {
+	return target == ErrUnexpectedResponse
+}
+
+// isTransient returns whether this request error is likely transient, so retryable.
+// That is a 5xx response (e.g. from a proxy), a network timeout, a connection reset
+// or refused, or the connection being dropped before a response was received
+func isTransient(err error) bool {
+	var serr *statusError
+	if errors.As(err, &serr) {
+		return serr.code >= 500
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var uerr *url.Error
+	return errors.As(err, &uerr) && (errors.Is(uerr.Err, io.EOF) || errors.Is(uerr.Err, io.ErrUnexpectedEOF))
+}
+
+// retrier tracks retries of transient failures within a bounded window,
+// waiting an exponentially increasing delay between each retry
+type retrier struct {
+	window time.Duration // window from first failure to allow retries, zero disables
+	delay  time.Duration // initial delay between retries
+	start  time.Time     // time of first failure, zero if none
+	next   time.Duration // delay before next retry
+}
+
+// retry waits out the delay before retrying a failure, returning false
+// if this is not allowed within the window (or ctx is done first)
+func (r *retrier) retry(ctx context.Context) bool {
+	if r.window <= 0 {
+		return false
+	}
+
+	// Track first failure
+	if r.start.IsZero() {
+		r.start = time.Now()
+		r.next = r.delay
+		if r.next <= 0 {
+			r.next = DefaultRetryDelay
+		}
+	}
+
+	// Check next retry is within window
+	if time.Since(r.start)+r.next > r.window {
+		return false
+	}
+
+	// Wait out delay
+	timer := time.NewTimer(r.next)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		r.next *= 2
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// giveUp returns the error to fail with once retrying err is no longer
+// allowed, marking the session closed should the window have been used
+func (r *retrier) giveUp(err error) error {
+	if r.window <= 0 || !isTransient(err) {
+		return err
+	}
+	return fmt.Errorf("%w (retry window elapsed): %v", ErrClosedConnection, err)
+}
+
+// reset marks success, so the next failure starts a new window
+func (r *retrier) reset() {
+	r.start = time.Time{}
+}
@@ -0,0 +1,92 @@
+package sockjsclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// timeoutError is a net.Error timing out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransient(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		transient bool
+	}{
+		{err: &statusError{code: 502}, transient: true},
+		{err: &statusError{code: 503}, transient: true},
+		{err: &statusError{code: 403}},
+		{err: &url.Error{Op: "Post", URL: "http://x", Err: timeoutError{}}, transient: true},
+		{err: &url.Error{Op: "Post", URL: "http://x", Err: io.EOF}, transient: true},
+		{err: &url.Error{Op: "Post", URL: "http://x", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, transient: true},
+		{err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, transient: true},
+		{err: &url.Error{Op: "Post", URL: "x://x", Err: errors.New("unsupported protocol scheme")}},
+		{err: io.ErrUnexpectedEOF},
+		{err: ErrInvalidResponse},
+	} {
+		if transient := isTransient(tc.err); transient != tc.transient {
+			t.Fatalf("error %q transience was not as expected: {Expect=%v Got=%v}", tc.err, tc.transient, transient)
+		}
+	}
+}
+
+func TestRetrierWindow(t *testing.T) {
+	ctx := context.Background()
+
+	// Disabled without a window
+	r := retrier{}
+	if r.retry(ctx) {
+		t.Fatal("expected no retry without window")
+	}
+
+	// Delays double (10ms, 20ms) until the next (40ms) would exceed the window
+	r = retrier{window: time.Millisecond * 50, delay: time.Millisecond * 10}
+	start := time.Now()
+	if !r.retry(ctx) || !r.retry(ctx) {
+		t.Fatal("expected retries within window")
+	} else if elapsed := time.Since(start); elapsed < time.Millisecond*30 {
+		t.Fatalf("expected retries to wait out delays, waited %v", elapsed)
+	} else if r.retry(ctx) {
+		t.Fatal("expected no retry beyond window")
+	}
+
+	// Success starts a new window
+	r.reset()
+	if !r.retry(ctx) {
+		t.Fatal("expected retry after reset")
+	}
+
+	// Done ctx gives up waiting
+	cctx, cncl := context.WithCancel(ctx)
+	cncl()
+	if r.retry(cctx) {
+		t.Fatal("expected no retry with done ctx")
+	}
+}
+
+func TestRetrierGiveUp(t *testing.T) {
+	transient := &statusError{code: 502}
+	r := retrier{window: time.Second}
+	if err := r.giveUp(transient); !errors.Is(err, ErrClosedConnection) {
+		t.Fatalf("expected ErrClosedConnection giving up transient error, got %v", err)
+	} else if err := r.giveUp(ErrInvalidResponse); err != ErrInvalidResponse {
+		t.Fatalf("expected error returned as is giving up permanent error, got %v", err)
+	}
+
+	// Without a window errors are returned as is
+	r = retrier{}
+	if err := r.giveUp(transient); err != transient {
+		t.Fatalf("expected error returned as is without window, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Message is an inbound message, decoded once and shared by all handlers it is routed to.
+// Handlers must treat it as read only
+type Message struct {
+	// Topic is the value of the first topic field present, empty if none is
+	Topic string
+
+	// Data is the message as received
+	Data []byte
+
+	// Fields holds the message's top level JSON fields, nil should it not be a JSON object
+	Fields map[string]json.RawMessage
+}
+
+// newMessage decodes data, taking its topic from the first of fields holding a string
+func newMessage(data []byte, fields []string) *Message {
+	msg := &Message{Data: data}
+	if err := json.Unmarshal(data, &msg.Fields); err != nil {
+		msg.Fields = nil
+		return msg
+	}
+	for _, name := range fields {
+		if raw, ok := msg.Fields[name]; ok && json.Unmarshal(raw, &msg.Topic) == nil {
+			break
+		}
+	}
+	return msg
+}
+
+// Decode decodes the whole message into v
+func (msg *Message) Decode(v interface{}) error {
+	return json.Unmarshal(msg.Data, v)
+}
+
+// Field decodes the message's top level field name into v
+func (msg *Message) Field(name string, v interface{}) error {
+	raw, ok := msg.Fields[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrNoField, name)
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"path"
+	"strings"
+)
+
+// matchKind is how a route matches message topics
+type matchKind int
+
+const (
+	matchExact = matchKind(iota)
+	matchPrefix
+	matchGlob
+)
+
+// Handler handles a message routed to it
+type Handler func(msg *Message)
+
+// Route is a handler registered with a Router
+type Route struct {
+	Pattern string
+
+	kind    matchKind
+	handler Handler
+	router  *Router
+}
+
+// matches returns whether the route matches topic
+func (rt *Route) matches(topic string) bool {
+	switch rt.kind {
+	case matchPrefix:
+		return strings.HasPrefix(topic, rt.Pattern)
+	case matchGlob:
+		ok, _ := path.Match(rt.Pattern, topic) // validated on registration
+		return ok
+	default:
+		return topic == rt.Pattern
+	}
+}
+
+// Remove unregisters the route, messages already routed to it are still handled
+func (rt *Route) Remove() {
+	rt.router.remove(rt)
+}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRouteMatches(t *testing.T) {
+	r := &Router{}
+	exact := r.Handle("chat.message", nil)
+	prefix := r.HandlePrefix("chat.", nil)
+	glob, err := r.HandlePattern("rooms/*/join", nil)
+	if err != nil {
+		t.Fatalf("error registering glob route: %v", err)
+	}
+
+	for _, tc := range []struct {
+		rt    *Route
+		topic string
+		exp   bool
+	}{
+		{rt: exact, topic: "chat.message", exp: true},
+		{rt: exact, topic: "chat.messages"},
+		{rt: prefix, topic: "chat.message", exp: true},
+		{rt: prefix, topic: "chat"},
+		{rt: glob, topic: "rooms/lobby/join", exp: true},
+		{rt: glob, topic: "rooms/a/b/join"},
+	} {
+		if got := tc.rt.matches(tc.topic); got != tc.exp {
+			t.Fatalf("route %q matching %q was not as expected: {Expect=%v Got=%v}", tc.rt.Pattern, tc.topic, tc.exp, got)
+		}
+	}
+
+	if _, err := r.HandlePattern("rooms/[", nil); !errors.Is(err, ErrInvalidPattern) {
+		t.Fatalf("expected ErrInvalidPattern, got %v", err)
+	}
+
+	prefix.Remove()
+	if len(r.routes) != 2 || r.routes[0] != exact || r.routes[1] != glob {
+		t.Fatalf("routes after removal were not as expected: %v", r.routes)
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	msg := newMessage([]byte(`{"type":3,"channel":"news","body":"hi"}`), DefaultTopicFields)
+	if msg.Topic != "news" {
+		t.Fatalf("message topic was not as expected: {Expect=news Got=%q}", msg.Topic)
+	}
+	var body string
+	if err := msg.Field("body", &body); err != nil || body != "hi" {
+		t.Fatalf("decoded field was not as expected: %q (err=%v)", body, err)
+	}
+	if err := msg.Field("missing", &body); !errors.Is(err, ErrNoField) {
+		t.Fatalf("expected ErrNoField, got %v", err)
+	}
+
+	msg = newMessage([]byte(`"plain"`), DefaultTopicFields)
+	if msg.Topic != "" || msg.Fields != nil {
+		t.Fatalf("non-object message was not as expected: %+v", msg)
+	}
+}
@@ -0,0 +1,198 @@
+// Package router routes inbound sockjs Client messages to handlers by the topic held
+// in a JSON field, matching handlers' topics exactly, by prefix or by glob pattern
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/internal/layer"
+)
+
+// DefaultConcurrency is the number of handlers run at once when no Concurrency is configured,
+// i.e. handlers run one at a time in the order messages are received
+const DefaultConcurrency = 1
+
+// DefaultTopicFields are the JSON fields checked for a message's topic when no TopicFields are configured
+var DefaultTopicFields = []string{"type", "channel"}
+
+// Router error messages
+var (
+	ErrNotConnected   = errors.New("router: not connected")
+	ErrInvalidPattern = errors.New("router: invalid pattern")
+	ErrNoField        = errors.New("router: no such field")
+)
+
+// Router reads messages from a sockjs Client, routing each to every handler matching
+// its topic in the order they were registered
+type Router struct {
+	// SockJS is the sockjs client messages are read from, configured but not yet connected
+	SockJS *sockjsclient.Client
+
+	// TopicFields are the JSON fields checked in turn for a message's topic,
+	// the first holding a string is used. Defaults to DefaultTopicFields
+	TopicFields []string
+
+	// Concurrency is the maximum number of handlers run at once. Zero means
+	// DefaultConcurrency. Reading stalls while all are busy
+	Concurrency int
+
+	// OnUnmatched is called with each message no handler matches
+	OnUnmatched func(msg *Message)
+
+	// OnPanic is called should a handler panic, with the message and recovered
+	// value. If nil, the panic is not recovered
+	OnPanic func(msg *Message, p interface{})
+
+	routes  []*Route        // registered routes, replaced on change
+	running sync.WaitGroup  // running handlers
+	life    layer.Lifecycle // read loop lifecycle, stopped once handlers returned
+	mu      sync.Mutex      // protects routes
+}
+
+func (r *Router) Connect() error {
+	return r.ConnectContext(context.Background())
+}
+
+// ConnectContext connects the sockjs client, giving up with ctx's error should it be done first
+func (r *Router) ConnectContext(ctx context.Context) error {
+	if err := r.SockJS.ConnectContext(ctx); err != nil {
+		return err
+	}
+
+	go r.readLoop(r.life.Start())
+
+	return nil
+}
+
+// readLoop reads and dispatches messages until the sockjs client fails or is closed
+func (r *Router) readLoop(run *layer.Run) {
+	err := r.dispatch()
+	r.running.Wait()
+	run.Stop(err)
+}
+
+// dispatch reads messages, running matching handlers for each, until an error occurs
+func (r *Router) dispatch() error {
+	fields := r.TopicFields
+	if fields == nil {
+		fields = DefaultTopicFields
+	}
+	size := r.Concurrency
+	if size <= 0 {
+		size = DefaultConcurrency
+	}
+	slots := make(chan struct{}, size)
+
+	for {
+		data, err := r.SockJS.ReadMsg()
+		if err != nil {
+			return err
+		}
+		msg := newMessage(data, fields)
+
+		r.mu.Lock()
+		routes := r.routes
+		r.mu.Unlock()
+
+		matched := false
+		for _, rt := range routes {
+			if !rt.matches(msg.Topic) {
+				continue
+			}
+			matched = true
+
+			// Run once a slot is free
+			slots <- struct{}{}
+			r.running.Add(1)
+			go func(handler Handler) {
+				defer func() {
+					<-slots
+					r.running.Done()
+				}()
+				r.run(handler, msg)
+			}(rt.handler)
+		}
+		if !matched && r.OnUnmatched != nil {
+			r.OnUnmatched(msg)
+		}
+	}
+}
+
+// run runs handler with msg, passing any panic to OnPanic
+func (r *Router) run(handler Handler, msg *Message) {
+	if r.OnPanic != nil {
+		defer func() {
+			if p := recover(); p != nil {
+				r.OnPanic(msg, p)
+			}
+		}()
+	}
+	handler(msg)
+}
+
+// Handle registers handler for messages whose topic is exactly topic
+func (r *Router) Handle(topic string, handler Handler) *Route {
+	return r.add(&Route{Pattern: topic, kind: matchExact, handler: handler})
+}
+
+// HandlePrefix registers handler for messages whose topic starts with prefix
+func (r *Router) HandlePrefix(prefix string, handler Handler) *Route {
+	return r.add(&Route{Pattern: prefix, kind: matchPrefix, handler: handler})
+}
+
+// HandlePattern registers handler for messages whose topic matches the glob pattern, per
+// path.Match (so '*' does not match '/')
+func (r *Router) HandlePattern(pattern string, handler Handler) (*Route, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPattern, pattern)
+	}
+	return r.add(&Route{Pattern: pattern, kind: matchGlob, handler: handler}), nil
+}
+
+// add registers rt
+func (r *Router) add(rt *Route) *Route {
+	rt.router = r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Copy on write, dispatch holds the old slice
+	routes := make([]*Route, len(r.routes), len(r.routes)+1)
+	copy(routes, r.routes)
+	r.routes = append(routes, rt)
+	return rt
+}
+
+// remove unregisters rt
+func (r *Router) remove(rt *Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	routes := make([]*Route, 0, len(r.routes))
+	for _, other := range r.routes {
+		if other != rt {
+			routes = append(routes, other)
+		}
+	}
+	r.routes = routes
+}
+
+// Done returns a channel that is closed once the router stops reading messages, i.e.
+// the sockjs client was closed or lost for good, and all running handlers have returned
+func (r *Router) Done() <-chan struct{} {
+	return r.life.Done()
+}
+
+// Err returns the error that stopped the router reading messages, nil until Done is
+// closed, or ErrNotConnected if never connected
+func (r *Router) Err() error {
+	return r.life.Err(ErrNotConnected)
+}
+
+// Close closes the sockjs client, running handlers are left to return
+func (r *Router) Close() error {
+	return r.SockJS.Close()
+}
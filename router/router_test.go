@@ -0,0 +1,219 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestRouterOrder(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	// Handlers run one at a time, in order received then registered
+	var got []string
+	var mu sync.Mutex
+	handled := make(chan struct{}, 16)
+	record := func(name string) Handler {
+		return func(msg *Message) {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			got = append(got, name+" "+msg.Topic)
+			mu.Unlock()
+			handled <- struct{}{}
+		}
+	}
+	r := &Router{SockJS: newTestSockJS(srv)}
+	r.HandlePrefix("chat.", record("prefix"))
+	r.Handle("chat.b", record("exact"))
+	session := connectTestRouter(t, srv, r)
+	defer r.Close()
+
+	session.Send(`{"type":"chat.a"}`, `{"type":"chat.b"}`, `{"channel":"chat.c"}`)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-handled:
+		case <-testContext(t).Done():
+			t.Fatal("timed out waiting for handlers")
+		}
+	}
+
+	exp := []string{"prefix chat.a", "prefix chat.b", "exact chat.b", "prefix chat.c"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("handled messages were not as expected: {Expect=%q Got=%q}", exp, got)
+	}
+}
+
+func TestRouterConcurrency(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	// Handlers block until released, tracking how many run at once
+	var running, most int
+	var mu sync.Mutex
+	started := make(chan struct{}, 16)
+	release := make(chan struct{})
+	r := &Router{SockJS: newTestSockJS(srv), Concurrency: 2}
+	r.Handle("work", func(*Message) {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+	session := connectTestRouter(t, srv, r)
+	defer r.Close()
+
+	// Only as many as allowed run, reading stalling until one returns
+	session.Send(`{"type":"work"}`, `{"type":"work"}`, `{"type":"work"}`)
+	for i := 0; i < 2; i++ {
+		<-started
+	}
+	select {
+	case <-started:
+		t.Fatal("expected third handler to wait for a free slot")
+	case <-time.After(time.Millisecond * 20):
+	}
+	release <- struct{}{}
+	<-started
+	close(release)
+
+	// Done once the read loop ends and handlers have returned
+	session.Close(sockjsclient.CloseGoAway, "Go away!")
+	select {
+	case <-r.Done():
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for done")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if most != 2 || running != 0 {
+		t.Fatalf("handler concurrency was not as expected: {Most=%d Running=%d}", most, running)
+	}
+}
+
+func TestRouterUnmatched(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	unmatched := make(chan *Message, 2)
+	r := &Router{
+		SockJS:      newTestSockJS(srv),
+		TopicFields: []string{"event"},
+		OnUnmatched: func(msg *Message) { unmatched <- msg },
+	}
+	r.Handle("known", func(*Message) {})
+	session := connectTestRouter(t, srv, r)
+	defer r.Close()
+
+	session.Send(`{"event":"known"}`, `{"type":"known"}`, `not json`)
+	for _, exp := range []string{`{"type":"known"}`, `not json`} {
+		select {
+		case msg := <-unmatched:
+			if string(msg.Data) != exp || msg.Topic != "" {
+				t.Fatalf("unmatched message was not as expected: {Expect=%s Message=%+v}", exp, msg)
+			}
+		case <-testContext(t).Done():
+			t.Fatalf("timed out waiting for unmatched %s", exp)
+		}
+	}
+}
+
+func TestRouterPanic(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	panics := make(chan string, 1)
+	handled := make(chan string, 1)
+	r := &Router{
+		SockJS:  newTestSockJS(srv),
+		OnPanic: func(msg *Message, p interface{}) { panics <- fmt.Sprintf("%s: %v", msg.Topic, p) },
+	}
+	r.Handle("bad", func(*Message) { panic("oops") })
+	r.Handle("good", func(msg *Message) { handled <- msg.Topic })
+	session := connectTestRouter(t, srv, r)
+	defer r.Close()
+
+	// Recovered, routing carrying on
+	session.Send(`{"type":"bad"}`, `{"type":"good"}`)
+	if p := <-panics; p != "bad: oops" {
+		t.Fatalf("recovered panic was not as expected: %s", p)
+	}
+	select {
+	case topic := <-handled:
+		if topic != "good" {
+			t.Fatalf("expected good handled after panic, got %s", topic)
+		}
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for handler after panic")
+	}
+}
+
+func TestRouterDone(t *testing.T) {
+	r := &Router{}
+	<-r.Done()
+	if err := r.Err(); err != ErrNotConnected {
+		t.Fatalf("expected ErrNotConnected before connect, got %v", err)
+	}
+
+	// Read loop ends on conn error
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	r.SockJS = newTestSockJS(srv)
+	session := connectTestRouter(t, srv, r)
+	defer r.Close()
+	if err := r.Err(); err != nil {
+		t.Fatalf("expected no error while reading, got %v", err)
+	}
+
+	session.SendFrame("x")
+	select {
+	case <-r.Done():
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for done")
+	}
+	if err := r.Err(); err == nil {
+		t.Fatal("expected error once conn failed")
+	}
+}
+
+// newTestSockJS returns a sockjs client for srv
+func newTestSockJS(srv *sockjstest.Server) *sockjsclient.Client {
+	return &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+	}
+}
+
+// connectTestRouter connects r to srv, returning its accepted session
+func connectTestRouter(t *testing.T, srv *sockjstest.Server, r *Router) *sockjstest.Session {
+	if err := r.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting router: %v", err)
+	}
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+	return session
+}
+
+// testContext returns a context bounding a single test step
+func testContext(t *testing.T) context.Context {
+	ctx, cncl := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cncl)
+	return ctx
+}
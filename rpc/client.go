@@ -0,0 +1,228 @@
+// Package rpc implements request / reply calls correlated by id over a sockjs Client
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/internal/layer"
+)
+
+// DefaultTimeout is the time to wait for a reply to a call whose context has
+// no deadline, when no Timeout is configured
+const DefaultTimeout = time.Second * 30
+
+// RPC client error messages
+var (
+	ErrNotConnected   = errors.New("rpc: client not connected")
+	ErrConnectionLost = errors.New("rpc: connection lost awaiting reply")
+)
+
+// Client makes calls over a sockjs Client, correlating replies to in-flight calls by id.
+// Calls in flight once the client stops reading, or the sockjs client starts reconnecting
+// (see sockjsclient.Client.Reconnect), fail with ErrConnectionLost after any replies
+// already received are passed on
+type Client struct {
+	// SockJS is the sockjs client carrying calls and replies, configured but not yet connected
+	SockJS *sockjsclient.Client
+
+	// Protocol encodes requests and correlates replies. Defaults to JSONRPC2
+	Protocol Protocol
+
+	// Timeout is the time to wait for a reply to a call whose context has
+	// no deadline. Zero means DefaultTimeout, negative waits indefinitely
+	Timeout time.Duration
+
+	// OnUnsolicited is called with each message received that is not a reply
+	// to an in-flight call. It is called from the client's read goroutine so must not block
+	OnUnsolicited func(msg []byte)
+
+	nextID uint64 // last generated call id, accessed atomically
+
+	pending map[uint64]chan reply // in-flight call reply waiters by id
+	life    layer.Lifecycle       // read loop lifecycle
+	mu      sync.Mutex            // protects pending
+}
+
+// reply is a reply message for an in-flight call, or the error failing it
+type reply struct {
+	msg []byte
+	err error
+}
+
+func (c *Client) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext connects the sockjs client, giving up with ctx's error should it be done first
+func (c *Client) ConnectContext(ctx context.Context) error {
+	// Fail in-flight calls on each reconnect, their replies lost with the connection
+	c.life.Hook(c.SockJS, func(ev sockjsclient.Event) {
+		if ev.Type == sockjsclient.EventReconnecting {
+			c.failPending(ev.Err)
+		}
+	})
+
+	// Connect underlying sockjs client
+	if err := c.SockJS.ConnectContext(ctx); err != nil {
+		return err
+	}
+
+	// Set up new session
+	c.mu.Lock()
+	c.pending = map[uint64]chan reply{}
+	run := c.life.Start()
+	c.mu.Unlock()
+
+	go c.readLoop(run)
+
+	return nil
+}
+
+// protocol returns the configured protocol
+func (c *Client) protocol() Protocol {
+	if c.Protocol == nil {
+		return JSONRPC2
+	}
+	return c.Protocol
+}
+
+// readLoop reads and routes messages until the sockjs client fails or is closed
+func (c *Client) readLoop(run *layer.Run) {
+	err := c.route()
+
+	// Fail in-flight calls, no more can be made
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+	run.Stop(err)
+	for _, ch := range pending {
+		ch <- reply{err: fmt.Errorf("%w: %v", ErrConnectionLost, err)} // buffered
+	}
+}
+
+// failPending fails the calls in flight with ErrConnectionLost caused by err,
+// leaving new calls to be made
+func (c *Client) failPending(err error) {
+	c.mu.Lock()
+	pending := c.pending
+	if pending != nil {
+		c.pending = map[uint64]chan reply{}
+	}
+	c.mu.Unlock()
+	for _, ch := range pending {
+		ch <- reply{err: fmt.Errorf("%w: %v", ErrConnectionLost, err)} // buffered
+	}
+}
+
+// route reads messages, passing replies to their waiting calls, until an error occurs
+func (c *Client) route() error {
+	protocol := c.protocol()
+	for {
+		msg, err := c.SockJS.ReadMsg()
+		if err != nil {
+			return err
+		}
+
+		// Pass reply to waiting call
+		if id, ok := protocol.ReplyID(msg); ok {
+			c.mu.Lock()
+			ch := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- reply{msg: msg} // buffered
+				continue
+			}
+		}
+
+		// Not a reply to any in-flight call
+		if c.OnUnsolicited != nil {
+			c.OnUnsolicited(msg)
+		}
+	}
+}
+
+// Call calls method with params (encoded by the protocol), waiting for the reply and decoding
+// its result into result (unless nil). Should ctx have no deadline, the call times out after
+// Timeout with context.DeadlineExceeded. An error reply is returned as a *RemoteError
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	protocol := c.protocol()
+	id := atomic.AddUint64(&c.nextID, 1)
+	msg, err := protocol.EncodeRequest(id, method, params)
+	if err != nil {
+		return err
+	}
+
+	// Apply default timeout
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.Timeout
+		if timeout == 0 {
+			timeout = DefaultTimeout
+		}
+		if timeout > 0 {
+			var cncl func()
+			ctx, cncl = context.WithTimeout(ctx, timeout)
+			defer cncl()
+		}
+	}
+
+	// Track as in-flight
+	ch := make(chan reply, 1)
+	c.mu.Lock()
+	if c.pending == nil {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.SockJS.WriteMsgContext(ctx, msg); err != nil {
+		return err
+	}
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return r.err
+		}
+		return protocol.DecodeReply(r.msg, result)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InFlight returns the number of calls awaiting a reply
+func (c *Client) InFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// Done returns a channel that is closed once the client stops reading
+// messages, i.e. the sockjs client was closed or lost for good
+func (c *Client) Done() <-chan struct{} {
+	return c.life.Done()
+}
+
+// Err returns the error that stopped the client reading messages, nil until Done is
+// closed, or ErrNotConnected if never connected
+func (c *Client) Err() error {
+	return c.life.Err(ErrNotConnected)
+}
+
+// Close closes the sockjs client, failing any in-flight calls
+func (c *Client) Close() error {
+	return c.SockJS.Close()
+}
@@ -0,0 +1,216 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+// testRequest is a JSON-RPC 2.0 request as received by the server
+type testRequest struct {
+	ID     uint64          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+func TestClientCall(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	unsolicited := make(chan string, 1)
+	c, session := connectTestClient(t, srv, func(msg []byte) { unsolicited <- string(msg) })
+	defer c.Close()
+
+	// Concurrent calls each receive their own reply, whatever the order
+	results := map[string]chan error{}
+	sums := map[string]*int{}
+	for _, method := range []string{"a", "b"} {
+		ch, sum := make(chan error, 1), new(int)
+		results[method], sums[method] = ch, sum
+		go func(method string) { ch <- c.Call(testContext(t), method, []int{1, 2}, sum) }(method)
+	}
+	ids := map[string]uint64{}
+	for range results {
+		req := recvTestRequest(t, session)
+		ids[req.Method] = req.ID
+	}
+	session.Send(
+		`{"jsonrpc":"2.0","method":"note","params":[]}`,
+		fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"error":{"code":-32601,"message":"no b"}}`, ids["b"]),
+		fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":3}`, ids["a"]),
+	)
+
+	if err := <-results["a"]; err != nil || *sums["a"] != 3 {
+		t.Fatalf("expected result 3 for a, got %d (err=%v)", *sums["a"], err)
+	}
+	var rerr *RemoteError
+	if err := <-results["b"]; !errors.As(err, &rerr) || rerr.Code != -32601 {
+		t.Fatalf("expected remote error for b, got %v", err)
+	}
+	if msg := <-unsolicited; msg != `{"jsonrpc":"2.0","method":"note","params":[]}` {
+		t.Fatalf("unsolicited message was not as expected: %s", msg)
+	} else if n := c.InFlight(); n != 0 {
+		t.Fatalf("expected no calls in flight, got %d", n)
+	}
+}
+
+func TestClientCallTimeout(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	unsolicited := make(chan string, 1)
+	c, session := connectTestClient(t, srv, func(msg []byte) { unsolicited <- string(msg) })
+	defer c.Close()
+
+	c.Timeout = time.Millisecond * 10
+	if err := c.Call(context.Background(), "slow", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected call timeout, got %v", err)
+	} else if n := c.InFlight(); n != 0 {
+		t.Fatalf("expected no calls in flight after timeout, got %d", n)
+	}
+
+	// Late reply treated as unsolicited
+	req := recvTestRequest(t, session)
+	reply := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":null}`, req.ID)
+	session.Send(reply)
+	if msg := <-unsolicited; msg != reply {
+		t.Fatalf("unsolicited message was not as expected: {Expect=%s Message=%s}", reply, msg)
+	}
+}
+
+func TestClientCallDisconnect(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	c, session := connectTestClient(t, srv, nil)
+	defer c.Close()
+
+	results := map[string]chan error{}
+	for _, method := range []string{"a", "b"} {
+		ch := make(chan error, 1)
+		results[method] = ch
+		go func(method string) { ch <- c.Call(testContext(t), method, nil, nil) }(method)
+	}
+	ids := map[string]uint64{}
+	for range results {
+		req := recvTestRequest(t, session)
+		ids[req.Method] = req.ID
+	}
+
+	// Reply received before the connection is lost still passed on, others failed
+	session.Send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":true}`, ids["a"]))
+	session.Close(sockjsclient.CloseGoAway, "Go away!")
+	if err := <-results["a"]; err != nil {
+		t.Fatalf("expected reply received before loss, got %v", err)
+	} else if err := <-results["b"]; !errors.Is(err, ErrConnectionLost) {
+		t.Fatalf("expected ErrConnectionLost, got %v", err)
+	}
+
+	<-c.Done()
+	if err := c.Err(); !sockjsclient.IsGoAway(err) {
+		t.Fatalf("expected go away close error, got %v", err)
+	} else if err := c.Call(testContext(t), "c", nil, nil); err != ErrNotConnected {
+		t.Fatalf("expected ErrNotConnected calling once done, got %v", err)
+	}
+}
+
+func TestClientCallReconnect(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+	c := &Client{
+		SockJS: &sockjsclient.Client{
+			Address:    srv.URL,
+			Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+			Reconnect:  &sockjsclient.ReconnectPolicy{InitialDelay: time.Millisecond},
+		},
+		Timeout: -1,
+	}
+	reconnected := make(chan struct{}, 1)
+	c.SockJS.AddEventHook(func(ev sockjsclient.Event) {
+		if ev.Type == sockjsclient.EventReconnected {
+			reconnected <- struct{}{}
+		}
+	})
+	if err := c.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting rpc client: %v", err)
+	}
+	defer c.Close()
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+
+	// Call in flight when reconnecting fails, rather than waiting indefinitely
+	result := make(chan error, 1)
+	go func() { result <- c.Call(context.Background(), "a", nil, nil) }()
+	recvTestRequest(t, session)
+	session.Close(1002, "Connection interrupted")
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrConnectionLost) {
+			t.Fatalf("expected ErrConnectionLost, got %v", err)
+		}
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for call in flight to fail")
+	}
+
+	// Calls made once reconnected are answered
+	session, err = srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting reconnected session: %v", err)
+	}
+	select {
+	case <-reconnected:
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for reconnect")
+	}
+	go func() { result <- c.Call(testContext(t), "b", nil, nil) }()
+	req := recvTestRequest(t, session)
+	session.Send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":true}`, req.ID))
+	if err := <-result; err != nil {
+		t.Fatalf("expected reply once reconnected, got %v", err)
+	}
+}
+
+// connectTestClient connects an rpc client passing unsolicited messages to onUnsolicited
+// to srv, returning it with its accepted session
+func connectTestClient(t *testing.T, srv *sockjstest.Server, onUnsolicited func([]byte)) (*Client, *sockjstest.Session) {
+	c := &Client{
+		SockJS: &sockjsclient.Client{
+			Address:    srv.URL,
+			Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+		},
+		OnUnsolicited: onUnsolicited,
+	}
+	if err := c.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting rpc client: %v", err)
+	}
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+	return c, session
+}
+
+// recvTestRequest receives the next request sent by the client over session
+func recvTestRequest(t *testing.T, session *sockjstest.Session) *testRequest {
+	msg, err := session.Recv(testContext(t))
+	if err != nil {
+		t.Fatalf("error receiving request from client: %v", err)
+	}
+	req := &testRequest{}
+	if err := json.Unmarshal([]byte(msg), req); err != nil {
+		t.Fatalf("error decoding request from client: %v", err)
+	}
+	return req
+}
+
+// testContext returns a context bounding a single test step
+func testContext(t *testing.T) context.Context {
+	ctx, cncl := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cncl)
+	return ctx
+}
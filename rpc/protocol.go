@@ -0,0 +1,138 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Protocol encodes call requests and correlates replies to them
+type Protocol interface {
+	// EncodeRequest returns the request message calling method with params under id
+	EncodeRequest(id uint64, method string, params interface{}) ([]byte, error)
+
+	// ReplyID returns the id of the call msg replies to, and false if it
+	// is not a reply (i.e. an unsolicited message)
+	ReplyID(msg []byte) (uint64, bool)
+
+	// DecodeReply decodes the result of reply msg into result, or returns
+	// the error it holds (as a *RemoteError where possible)
+	DecodeReply(msg []byte, result interface{}) error
+}
+
+// RemoteError is an error returned by the remote end in reply to a call
+type RemoteError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("rpc: remote error %d: %s", e.Code, e.Message)
+}
+
+// FieldProtocol is a Protocol for JSON object messages, correlating replies to calls
+// by a configurable id field. Field names default to those of JSON-RPC
+type FieldProtocol struct {
+	// IDField holds the call id in requests and replies. Defaults to "id"
+	IDField string
+
+	// MethodField holds the method name in requests. Defaults to "method"
+	MethodField string
+
+	// ParamsField holds the call params in requests. Defaults to "params"
+	ParamsField string
+
+	// ResultField holds the call result in replies. Defaults to "result"
+	ResultField string
+
+	// ErrorField holds the call error in replies. Defaults to "error"
+	ErrorField string
+
+	// Extra holds any fields to add to every request, e.g. a protocol version
+	Extra map[string]interface{}
+}
+
+// JSONRPC2 is the JSON-RPC 2.0 protocol preset
+var JSONRPC2 Protocol = &FieldProtocol{
+	Extra: map[string]interface{}{"jsonrpc": "2.0"},
+}
+
+// field returns name, or def if empty
+func field(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name
+}
+
+// EncodeRequest implements Protocol.EncodeRequest()
+func (p *FieldProtocol) EncodeRequest(id uint64, method string, params interface{}) ([]byte, error) {
+	req := make(map[string]interface{}, len(p.Extra)+3)
+	for key, value := range p.Extra {
+		req[key] = value
+	}
+	req[field(p.IDField, "id")] = id
+	req[field(p.MethodField, "method")] = method
+	if params != nil {
+		req[field(p.ParamsField, "params")] = params
+	}
+	return json.Marshal(req)
+}
+
+// ReplyID implements Protocol.ReplyID(). Messages are only replies if they hold
+// an id, no method and a result or error, so requests from the remote end are unsolicited
+func (p *FieldProtocol) ReplyID(msg []byte) (uint64, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return 0, false
+	}
+
+	// Check reply fields present
+	if _, ok := fields[field(p.MethodField, "method")]; ok {
+		return 0, false
+	}
+	_, hasResult := fields[field(p.ResultField, "result")]
+	_, hasError := fields[field(p.ErrorField, "error")]
+	if !hasResult && !hasError {
+		return 0, false
+	}
+
+	// Parse id, number or numeric string
+	raw := bytes.Trim(fields[field(p.IDField, "id")], `"`)
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// DecodeReply implements Protocol.DecodeReply()
+func (p *FieldProtocol) DecodeReply(msg []byte, result interface{}) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		return err
+	}
+
+	// Check for error, decoding as RemoteError where possible
+	if raw, ok := fields[field(p.ErrorField, "error")]; ok && !isNull(raw) {
+		rerr := &RemoteError{}
+		if err := json.Unmarshal(raw, rerr); err != nil || rerr.Message == "" {
+			rerr = &RemoteError{Message: string(raw), Data: raw}
+		}
+		return rerr
+	}
+
+	// Decode result if wanted
+	raw, ok := fields[field(p.ResultField, "result")]
+	if !ok || result == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// isNull returns whether raw is a JSON null
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestJSONRPC2EncodeRequest(t *testing.T) {
+	b, err := JSONRPC2.EncodeRequest(7, "sum", []int{1, 2})
+	if err != nil {
+		t.Fatalf("error encoding request: %v", err)
+	}
+	exp := `{"id":7,"jsonrpc":"2.0","method":"sum","params":[1,2]}`
+	if string(b) != exp {
+		t.Fatalf("encoded request was not as expected: {Expect=%s Got=%s}", exp, b)
+	}
+}
+
+func TestFieldProtocolReplyID(t *testing.T) {
+	p := &FieldProtocol{IDField: "tag", ResultField: "data"}
+	for _, tc := range []struct {
+		msg   string
+		id    uint64
+		reply bool
+	}{
+		{msg: `{"tag":3,"data":true}`, id: 3, reply: true},
+		{msg: `{"tag":"12","error":"nope"}`, id: 12, reply: true},
+		{msg: `{"tag":3,"method":"ping","data":true}`},
+		{msg: `{"tag":3}`},
+		{msg: `{"data":true}`},
+		{msg: `{"tag":"abc","data":true}`},
+		{msg: `not json`},
+	} {
+		id, ok := p.ReplyID([]byte(tc.msg))
+		if ok != tc.reply || id != tc.id {
+			t.Fatalf("reply id of %s was not as expected: {Expect=%d,%v Got=%d,%v}", tc.msg, tc.id, tc.reply, id, ok)
+		}
+	}
+}
+
+func TestFieldProtocolDecodeReply(t *testing.T) {
+	var sum int
+	if err := JSONRPC2.DecodeReply([]byte(`{"jsonrpc":"2.0","id":1,"result":3}`), &sum); err != nil {
+		t.Fatalf("error decoding result: %v", err)
+	} else if sum != 3 {
+		t.Fatalf("decoded result was not as expected: {Expect=3 Got=%d}", sum)
+	}
+
+	// Structured error
+	err := JSONRPC2.DecodeReply([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`), &sum)
+	var rerr *RemoteError
+	if !errors.As(err, &rerr) || rerr.Code != -32601 || rerr.Message != "Method not found" {
+		t.Fatalf("decoded error was not as expected: %v", err)
+	}
+
+	// Unstructured error
+	err = JSONRPC2.DecodeReply([]byte(`{"id":1,"error":"denied"}`), nil)
+	if !errors.As(err, &rerr) || string(rerr.Data) != `"denied"` {
+		t.Fatalf("decoded unstructured error was not as expected: %v", err)
+	}
+
+	// Null error alongside result
+	var raw json.RawMessage
+	if err := JSONRPC2.DecodeReply([]byte(`{"id":1,"error":null,"result":{"a":1}}`), &raw); err != nil {
+		t.Fatalf("error decoding result with null error: %v", err)
+	} else if string(raw) != `{"a":1}` {
+		t.Fatalf("decoded result was not as expected: %s", raw)
+	}
+}
@@ -1,7 +1,9 @@
 package sockjsclient
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"path"
@@ -9,6 +11,18 @@ import (
 
 // GetServerInfo attempts to fetch sockjs ServerInfo for given address and parse server addr
 func GetServerInfo(addr string) (*ServerInfo, *url.URL, error) {
+	return GetServerInfoContext(context.Background(), http.DefaultClient, addr, nil)
+}
+
+// GetServerInfoContext attempts to fetch sockjs ServerInfo for given address and parse server addr,
+// performing the request using given HTTP client (or default if nil) and headers. Non-200 responses
+// return a *ServerInfoError
+func GetServerInfoContext(ctx context.Context, client *http.Client, addr string, hdrs http.Header) (*ServerInfo, *url.URL, error) {
+	// Ensure an HTTP client is set
+	if client == nil {
+		client = http.DefaultClient
+	}
+
 	// Ensure valid provided addr
 	url, err := url.Parse(addr)
 	if err != nil {
@@ -26,13 +40,30 @@ func GetServerInfo(addr string) (*ServerInfo, *url.URL, error) {
 	u := *url
 	u.Path = path.Join(url.Path, "/info")
 
+	// Prepare request to endpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range hdrs {
+		req.Header[key] = values
+	}
+
 	// Perform request to endpoint
-	rsp, err := http.Get(u.String())
+	rsp, err := client.Do(req)
 	if err != nil {
 		return nil, nil, err
 	}
 	defer rsp.Body.Close()
 
+	// Check for successful response
+	if rsp.StatusCode != http.StatusOK {
+		return nil, nil, &ServerInfoError{
+			StatusCode: rsp.StatusCode,
+			Status:     rsp.Status,
+		}
+	}
+
 	// Decoded received response
 	info := ServerInfo{}
 	err = json.NewDecoder(rsp.Body).Decode(&info)
@@ -50,3 +81,23 @@ type ServerInfo struct {
 	Origins      []string `json:"origins"`
 	Entropy      int      `json:"entropy"`
 }
+
+// ServerInfoError represents an unsuccessful (non-200) "/info" response from
+// a sockjs server, matching ErrUnexpectedResponse with errors.Is
+type ServerInfoError struct {
+	// StatusCode is the HTTP response status code
+	StatusCode int
+
+	// Status is the HTTP response status line, e.g. "503 Service Unavailable"
+	Status string
+}
+
+// Error implements error
+func (err *ServerInfoError) Error() string {
+	return fmt.Sprintf("%s (info endpoint HTTP %s)", ErrUnexpectedResponse.Error(), err.Status)
+}
+
+// Is returns whether target is ErrUnexpectedResponse
+func (err *ServerInfoError) Is(target error) bool {
+	return target == ErrUnexpectedResponse
+}
@@ -0,0 +1,46 @@
+package sockjsclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestGetServerInfoContext(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	// Nil HTTP client falls back to default
+	info, url, err := sockjsclient.GetServerInfoContext(context.Background(), nil, srv.URL, http.Header{"X-Test": {"yes"}})
+	if err != nil {
+		t.Fatalf("error fetching server info: %v", err)
+	} else if !info.WebSocket || url.String() != srv.URL {
+		t.Fatalf("server info was not as expected: %+v (url=%s)", info, url)
+	}
+
+	// Headers are passed along
+	reqs := srv.Requests()
+	if len(reqs) != 1 || reqs[0].URL.Path != sockjstest.Prefix+"/info" || reqs[0].Header.Get("X-Test") != "yes" {
+		t.Fatalf("info request was not as expected: %+v", reqs)
+	}
+}
+
+func TestGetServerInfoContextNon200(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, _, err := sockjsclient.GetServerInfoContext(context.Background(), srv.Client(), srv.URL+"/sockjs", nil)
+	var ierr *sockjsclient.ServerInfoError
+	if !errors.As(err, &ierr) || ierr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 ServerInfoError, got %v", err)
+	} else if !errors.Is(err, sockjsclient.ErrUnexpectedResponse) {
+		t.Fatalf("expected error to match ErrUnexpectedResponse, got %v", err)
+	}
+}
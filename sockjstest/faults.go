@@ -0,0 +1,303 @@
+package sockjstest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault is a kind of transport fault injected by Faults
+type Fault string
+
+// Injected transport faults
+const (
+	FaultLatency        = Fault("latency")
+	FaultDropPoll       = Fault("drop-poll")
+	FaultBadGateway     = Fault("bad-gateway")
+	FaultNotFound       = Fault("not-found")
+	FaultTruncate       = Fault("truncate")
+	FaultStallHeartbeat = Fault("stall-heartbeat")
+	FaultSeverWebsocket = Fault("sever-websocket")
+)
+
+// Faults injects transport faults in front of a sockjs server handler. Each decision for a
+// fault is derived from Seed, the fault and the number of decisions already taken for it,
+// so a given seed injects the same faults wherever each fault's decisions are taken in the
+// same order, i.e. for serial traffic. Concurrent requests may take them in differing order.
+// Frames are recognised per response write, as written by Server. Probabilities are in the
+// range [0, 1], zero disabling the fault
+type Faults struct {
+	// Seed seeds the pseudo-random sequences deciding faults
+	Seed int64
+
+	// Latency delays each request, and each frame written on a streaming or websocket
+	// connection, by Latency plus a random duration up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// DropPoll is the probability of an xhr poll being dropped, its
+	// connection closed before reaching the server
+	DropPoll float64
+
+	// BadGateway and NotFound are the probabilities of a request for an already opened
+	// session being answered 502 Bad Gateway or 404 Not Found, without reaching the server
+	BadGateway float64
+	NotFound   float64
+
+	// Truncate is the probability of a data frame written over http being cut short
+	Truncate float64
+
+	// StallHeartbeats swallows all heartbeat frames, an xhr poll answering with one never returns
+	StallHeartbeats bool
+
+	// SeverWebsocket is the probability of a websocket connection being closed
+	// abruptly (without close frame) in place of writing each frame after open
+	SeverWebsocket float64
+
+	decided  map[Fault]uint64 // decisions taken by fault
+	sessions map[string]bool  // seen session ids
+	injected map[Fault]int    // injected fault counts
+	mu       sync.Mutex       // protects decided, sessions, injected
+}
+
+// NewFaultyServer returns a started Server, with faults injected in front of it
+func NewFaultyServer(f *Faults) *Server {
+	s := NewUnstartedServer()
+	s.HTTP.Config.Handler = f.Handler(s)
+	s.Start()
+	return s
+}
+
+// Handler returns h wrapped to inject faults
+func (f *Faults) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.serve(h, w, r)
+	})
+}
+
+// roll returns whether a fault with probability p occurs, counting it if so
+func (f *Faults) roll(fault Fault, p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.init()
+	if float64(f.next(fault)>>11)/(1<<53) >= p {
+		return false
+	}
+	f.injected[fault]++
+	return true
+}
+
+// count counts an injected fault
+func (f *Faults) count(fault Fault) {
+	f.mu.Lock()
+	f.init()
+	f.injected[fault]++
+	f.mu.Unlock()
+}
+
+// init initialises fault state on first use, must be called with mu held
+func (f *Faults) init() {
+	if f.decided == nil {
+		f.decided = map[Fault]uint64{}
+		f.sessions = map[string]bool{}
+		f.injected = map[Fault]int{}
+	}
+}
+
+// next returns the pseudo-random value for the next decision for fault, must be called with mu held
+func (f *Faults) next(fault Fault) uint64 {
+	n := f.decided[fault]
+	f.decided[fault]++
+
+	h := fnv.New64a()
+	h.Write([]byte(fault))
+	return splitmix64((uint64(f.Seed) ^ h.Sum64()) + n*0x9e3779b97f4a7c15)
+}
+
+// splitmix64 returns the SplitMix64 output for state x
+func splitmix64(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// Injected returns the number of times fault has been injected
+func (f *Faults) Injected(fault Fault) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.injected[fault]
+}
+
+// delay sleeps for the configured latency, if any
+func (f *Faults) delay() {
+	if f.Latency <= 0 && f.Jitter <= 0 {
+		return
+	}
+	d := f.Latency
+	if f.Jitter > 0 {
+		f.mu.Lock()
+		f.init()
+		d += time.Duration(f.next(FaultLatency) % uint64(f.Jitter+1))
+		f.mu.Unlock()
+	}
+	f.count(FaultLatency)
+	time.Sleep(d)
+}
+
+// seen returns whether session id was already seen, marking it seen
+func (f *Faults) seen(id string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.init()
+	seen := f.sessions[id]
+	f.sessions[id] = true
+	return seen
+}
+
+// serve serves r with h, injecting faults
+func (f *Faults) serve(h http.Handler, w http.ResponseWriter, r *http.Request) {
+	f.delay()
+
+	// Split into /{server}/{session}/{transport}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 {
+		h.ServeHTTP(w, r) // i.e. info
+		return
+	}
+	id, transport := parts[len(parts)-2], parts[len(parts)-1]
+
+	// Faults short of reaching the server
+	if f.seen(id) {
+		if transport == "xhr" && f.roll(FaultDropPoll, f.DropPoll) {
+			panic(http.ErrAbortHandler)
+		}
+		if f.roll(FaultBadGateway, f.BadGateway) {
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			return
+		} else if f.roll(FaultNotFound, f.NotFound) {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	h.ServeHTTP(&faultWriter{
+		ResponseWriter: w,
+		faults:         f,
+		req:            r,
+		stream:         transport == "xhr_streaming" || transport == "eventsource" || transport == "htmlfile",
+	}, r)
+}
+
+// faultWriter wraps a response writer, injecting faults into the frames written
+type faultWriter struct {
+	http.ResponseWriter
+	faults *Faults
+	req    *http.Request
+	stream bool
+}
+
+// Write implements io.Writer, each write holding a single frame (or stream prelude)
+func (fw *faultWriter) Write(b []byte) (int, error) {
+	f := fw.faults
+	size := len(b)
+	frame := strings.TrimSpace(strings.TrimPrefix(string(b), "data: "))
+	switch {
+	// Stall heartbeats, holding any poll answering with one
+	case frame == "h" && f.StallHeartbeats:
+		f.count(FaultStallHeartbeat)
+		if !fw.stream {
+			<-fw.req.Context().Done()
+			return 0, fw.req.Context().Err()
+		}
+		return len(b), nil
+
+	// Cut data frames short
+	case strings.HasPrefix(frame, "a") && f.roll(FaultTruncate, f.Truncate):
+		b = []byte(strings.Replace(string(b), frame, frame[:len(frame)/2], 1))
+	}
+
+	if fw.stream && frame != "" && strings.Trim(frame, "h") != "" {
+		f.delay()
+	}
+	if _, err := fw.ResponseWriter.Write(b); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Flush implements http.Flusher
+func (fw *faultWriter) Flush() {
+	if flusher, ok := fw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, wrapping the connection to inject websocket faults
+func (fw *faultWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := fw.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &faultConn{Conn: conn, faults: fw.faults}, brw, nil
+}
+
+// faultConn wraps a hijacked websocket connection, injecting faults into the frames written
+type faultConn struct {
+	net.Conn
+	faults *Faults
+}
+
+// Write implements net.Conn, recognising single unfragmented server text frames
+func (fc *faultConn) Write(b []byte) (int, error) {
+	f := fc.faults
+	frame, ok := textFrame(b)
+	if !ok {
+		return fc.Conn.Write(b) // not a frame we recognise, e.g. handshake
+	}
+
+	switch {
+	// Swallow heartbeats
+	case frame == "h" && f.StallHeartbeats:
+		f.count(FaultStallHeartbeat)
+		return len(b), nil
+
+	// Sever connection in place of frame
+	case frame != "o" && f.roll(FaultSeverWebsocket, f.SeverWebsocket):
+		fc.Conn.Close()
+		return 0, io.ErrClosedPipe
+	}
+
+	f.delay()
+	return fc.Conn.Write(b)
+}
+
+// textFrame returns the payload of b should it hold exactly one unmasked,
+// unfragmented websocket text frame of under 64KiB
+func textFrame(b []byte) (string, bool) {
+	if len(b) < 2 || b[0] != 0x81 {
+		return "", false
+	}
+	switch size := int(b[1]); {
+	case size < 126:
+		if len(b) != 2+size {
+			return "", false
+		}
+		return string(b[2:]), true
+	case size == 126 && len(b) >= 4:
+		if len(b) != 4+int(binary.BigEndian.Uint16(b[2:4])) {
+			return "", false
+		}
+		return string(b[4:]), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,72 @@
+package sockjstest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFaultsDeterministic(t *testing.T) {
+	rolls := func(seed int64) []bool {
+		f := &Faults{Seed: seed}
+		out := make([]bool, 32)
+		for i := range out {
+			out[i] = f.roll(FaultBadGateway, 0.5)
+		}
+		return out
+	}
+
+	a, b := rolls(42), rolls(42)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("faults with same seed differed at roll %d", i)
+		}
+	}
+
+	// Unaffected by decisions for other faults
+	f := &Faults{Seed: 42, Jitter: time.Nanosecond}
+	for i := range a {
+		f.roll(FaultNotFound, 0.5)
+		f.delay()
+		if f.roll(FaultBadGateway, 0.5) != a[i] {
+			t.Fatalf("faults interleaved with others differed at roll %d", i)
+		}
+	}
+
+	// Differing between seeds and faults
+	if c := rolls(43); reflect.DeepEqual(a, c) {
+		t.Fatal("expected faults to differ between seeds")
+	}
+	f = &Faults{Seed: 42}
+	same := true
+	for i := range a {
+		same = same && f.roll(FaultNotFound, 0.5) == a[i]
+	}
+	if same {
+		t.Fatal("expected faults to differ between fault kinds")
+	}
+}
+
+func TestTextFrame(t *testing.T) {
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = 'a'
+	}
+
+	for _, tc := range []struct {
+		b     []byte
+		frame string
+		ok    bool
+	}{
+		{b: []byte{0x81, 1, 'h'}, frame: "h", ok: true},
+		{b: append([]byte{0x81, 126, 1, 44}, long...), frame: string(long), ok: true},
+		{b: []byte{0x81, 2, 'h'}},
+		{b: []byte{0x82, 1, 'h'}},
+		{b: []byte("HTTP/1.1 101 Switching Protocols\r\n")},
+	} {
+		frame, ok := textFrame(tc.b)
+		if ok != tc.ok || frame != tc.frame {
+			t.Fatalf("text frame of %q was not as expected: {Expect=%v Got=%v}", tc.b, tc.ok, ok)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Package sockjstest provides an in-process sockjs server for testing sockjs clients, speaking the
+// websocket, xhr, xhr_streaming, eventsource, htmlfile and jsonp transports. Tests script the frames
+// sent to each session (data, heartbeats, close codes, malformed frames or stream rotation) and
+// inspect what the client sent
+package sockjstest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/rodneyVW/go-sockjsclient"
+)
+
+// Prefix is the path the sockjs endpoints are served under
+const Prefix = "/sockjs"
+
+// Test server error messages
+var (
+	ErrSessionClosed  = errors.New("sockjstest: session closed")
+	ErrServerClosed   = errors.New("sockjstest: server closed")
+	ErrInvalidPayload = errors.New("sockjstest: invalid payload")
+)
+
+// Request is a request received by the server, as recorded for inspection
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+}
+
+// Server is an in-process sockjs server, backed by an httptest.Server
+type Server struct {
+	// URL is the sockjs base address, for use as sockjsclient.Client Address
+	URL string
+
+	// HTTP is the underlying HTTP test server, its handler defaults to the Server.
+	// Its handler may be replaced (e.g. wrapped) before calling Start on an unstarted server
+	HTTP *httptest.Server
+
+	// Info is served by the /info endpoint
+	Info sockjsclient.ServerInfo
+
+	// Open is the open frame written to each new session. Defaults to "o"
+	Open string
+
+	sessions map[string]*Session // sessions by id
+	accepted chan *Session       // new sessions awaiting Accept
+	requests []Request           // all requests received
+	closed   chan struct{}       // closed on server close
+	mu       sync.Mutex          // protects sessions, requests
+}
+
+// NewServer returns a started Server
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a Server that is not yet started, so it
+// may be configured first. Start it with Start
+func NewUnstartedServer() *Server {
+	s := &Server{
+		Info: sockjsclient.ServerInfo{
+			WebSocket: true,
+			Origins:   []string{"*:*"},
+			Entropy:   1,
+		},
+		Open:     "o",
+		sessions: map[string]*Session{},
+		accepted: make(chan *Session, 64),
+		closed:   make(chan struct{}),
+	}
+	s.HTTP = httptest.NewUnstartedServer(s)
+	return s
+}
+
+// Start starts the server
+func (s *Server) Start() {
+	s.HTTP.Start()
+	s.URL = s.HTTP.URL + Prefix
+}
+
+// Close ends all sessions and shuts down the server
+func (s *Server) Close() {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+	for _, sess := range s.sessions {
+		sess.end()
+	}
+	s.mu.Unlock()
+	s.HTTP.CloseClientConnections()
+	s.HTTP.Close()
+}
+
+// Accept returns the next session opened by a client (of up to 64 queued),
+// giving up with ctx's error should it be done first
+func (s *Server) Accept(ctx context.Context) (*Session, error) {
+	select {
+	case sess := <-s.accepted:
+		return sess, nil
+	case <-s.closed:
+		return nil, ErrServerClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Session returns the session with id, or nil if there is none
+func (s *Server) Session(id string) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+// Requests returns all requests received by the server so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ServeHTTP implements http.Handler, serving the sockjs endpoints under Prefix
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		URL:    r.URL,
+		Header: r.Header.Clone(),
+	})
+	s.mu.Unlock()
+
+	// Split into /{server}/{session}/{transport}
+	p := strings.TrimPrefix(r.URL.Path, Prefix)
+	if p == "/info" {
+		s.serveInfo(w, r)
+		return
+	}
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	id, transport := parts[1], parts[2]
+
+	switch transport {
+	case "xhr_send":
+		s.serveSend(w, r, id)
+	case "websocket":
+		if !s.Info.WebSocket {
+			http.NotFound(w, r)
+			return
+		}
+		s.serveWebsocket(w, r, id)
+	case "jsonp_send":
+		s.serveJSONPSend(w, r, id)
+	case "xhr":
+		s.servePoll(w, r, id, transport, pollFraming)
+	case "jsonp":
+		if c, ok := callback(w, r); ok {
+			s.servePoll(w, r, id, transport, jsonpFraming(c))
+		}
+	case "xhr_streaming":
+		s.serveStream(w, r, id, transport, streamFraming)
+	case "eventsource":
+		s.serveStream(w, r, id, transport, eventSourceFraming)
+	case "htmlfile":
+		if c, ok := callback(w, r); ok {
+			s.serveStream(w, r, id, transport, htmlFileFraming(c))
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveInfo serves the /info endpoint
+func (s *Server) serveInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(s.Info) // failure is seen by client
+}
+
+// session returns the session with id, opening it for transport if new
+func (s *Server) session(id, transport string, r *http.Request) (sess *Session, opened bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess := s.sessions[id]; sess != nil {
+		return sess, false
+	}
+	sess = newSession(id, transport, r)
+	s.sessions[id] = sess
+	select {
+	case <-s.closed:
+		sess.end()
+		return sess, true
+	default:
+	}
+	select {
+	case s.accepted <- sess:
+	default:
+		// Accept backlog full, still available via Session
+	}
+	return sess, true
+}
@@ -0,0 +1,108 @@
+package sockjstest
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerJSONP(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	addr := srv.URL + "/000/jsonp-session"
+
+	// Open, frames wrapped in callback
+	if body := testGet(t, addr+"/jsonp?c=cb"); body != "/**/cb(\"o\");\r\n" {
+		t.Fatalf("unexpected open response %q", body)
+	}
+	sess := srv.Session("jsonp-session")
+	sess.Send("a\"b")
+	if body := testGet(t, addr+"/jsonp?c=cb"); body != "/**/cb(\"a[\\\"a\\\\\\\"b\\\"]\");\r\n" {
+		t.Fatalf("unexpected data response %q", body)
+	}
+
+	// Missing callback refused
+	if rsp, err := http.Get(addr + "/jsonp"); err != nil {
+		t.Fatalf("error polling: %v", err)
+	} else if rsp.Body.Close(); rsp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected missing callback refused, got HTTP %d", rsp.StatusCode)
+	}
+
+	// Sends accepted form-encoded
+	rsp, err := http.PostForm(addr+"/jsonp_send", url.Values{"d": {`["x"]`}})
+	if err != nil {
+		t.Fatalf("error sending: %v", err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("expected send accepted, got HTTP %d", rsp.StatusCode)
+	}
+	if msg, err := sess.Recv(testContext(t)); err != nil || msg != "x" {
+		t.Fatalf("expected message sent, got %q (err=%v)", msg, err)
+	}
+}
+
+func TestServerHTMLFileRotate(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	addr := srv.URL + "/000/htmlfile-session/htmlfile?c=cb"
+
+	rsp, err := http.Get(addr)
+	if err != nil {
+		t.Fatalf("error opening stream: %v", err)
+	}
+	defer rsp.Body.Close()
+	r := bufio.NewReader(rsp.Body)
+
+	// Page calls back to parent, then frames follow as scripts
+	sess := srv.Session("htmlfile-session")
+	sess.Send("hi")
+	sess.Rotate()
+	var calls []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break // rotated
+		}
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "p(") || strings.Contains(line, "parent.") {
+			calls = append(calls, line)
+		}
+	}
+	exp := []string{"var c = parent.cb;", `p("o");`, `p("a[\"hi\"]");`}
+	if strings.Join(calls, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("stream was not as expected: {Expect=%q Got=%q}", exp, calls)
+	}
+
+	// Session still open for reopened stream
+	select {
+	case <-sess.Done():
+		t.Fatal("expected session open after rotation")
+	default:
+	}
+}
+
+// testGet returns the body of a GET request to addr
+func testGet(t *testing.T, addr string) string {
+	rsp, err := http.Get(addr)
+	if err != nil {
+		t.Fatalf("error requesting %s: %v", addr, err)
+	}
+	defer rsp.Body.Close()
+	b, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatalf("error reading %s: %v", addr, err)
+	}
+	return string(b)
+}
+
+// testContext returns a context bounding a single test step
+func testContext(t *testing.T) context.Context {
+	ctx, cncl := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cncl)
+	return ctx
+}
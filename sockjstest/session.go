@@ -0,0 +1,184 @@
+package sockjstest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// queue is an unbounded FIFO of frames or messages, with a single consumer
+type queue struct {
+	items []string      // queued items
+	ready chan struct{} // signalled on push
+	mu    sync.Mutex    // protects items
+}
+
+func newQueue() *queue {
+	return &queue{ready: make(chan struct{}, 1)}
+}
+
+// push appends items to the queue
+func (q *queue) push(items ...string) {
+	q.mu.Lock()
+	q.items = append(q.items, items...)
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the next item, giving up with ErrSessionClosed should done be closed
+// first (once the queue is empty), or ctx's error should it be done first
+func (q *queue) pop(ctx context.Context, done <-chan struct{}) (string, error) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			item := q.items[0]
+			q.items = q.items[1:]
+			q.mu.Unlock()
+			return item, nil
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.ready:
+		case <-done:
+			// Check for any pushed before close
+			q.mu.Lock()
+			n := len(q.items)
+			q.mu.Unlock()
+			if n == 0 {
+				return "", ErrSessionClosed
+			}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// Session is a sockjs session opened by a client. Frames queued with Send, Heartbeat,
+// Close and SendFrame are written to the client in order by the session's transport
+type Session struct {
+	// ID is the client-generated session id
+	ID string
+
+	// Transport is the transport endpoint the session was opened with, i.e. "websocket",
+	// "xhr", "xhr_streaming", "eventsource", "htmlfile" or "jsonp"
+	Transport string
+
+	// Header and Query are those of the request opening the session
+	Header http.Header
+	Query  url.Values
+
+	out  *queue        // frames to write to client
+	in   *queue        // messages received from client
+	done chan struct{} // closed on session end
+	once sync.Once     // protects done
+}
+
+func newSession(id, transport string, r *http.Request) *Session {
+	return &Session{
+		ID:        id,
+		Transport: transport,
+		Header:    r.Header.Clone(),
+		Query:     r.URL.Query(),
+		out:       newQueue(),
+		in:        newQueue(),
+		done:      make(chan struct{}),
+	}
+}
+
+// end ends the session, only the first call has any effect
+func (s *Session) end() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// ended returns whether the session has ended
+func (s *Session) ended() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// next takes the next frame to write to the client
+func (s *Session) next(ctx context.Context) (string, error) {
+	return s.out.pop(ctx, s.done)
+}
+
+// receive queues the messages of a sockjs message block sent by the client,
+// accepting a JSON array of strings or a single JSON string
+func (s *Session) receive(b []byte) error {
+	var msgs []string
+	if err := json.Unmarshal(b, &msgs); err != nil {
+		var msg string
+		if json.Unmarshal(b, &msg) != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidPayload, b)
+		}
+		msgs = []string{msg}
+	}
+	s.in.push(msgs...)
+	return nil
+}
+
+// SendFrame queues a raw sockjs frame, which may be malformed. Once a
+// close frame (starting 'c') is written, the session ends
+func (s *Session) SendFrame(frame string) error {
+	if s.ended() {
+		return ErrSessionClosed
+	}
+	s.out.push(frame)
+	return nil
+}
+
+// Send queues a data frame holding msgs
+func (s *Session) Send(msgs ...string) error {
+	b, err := json.Marshal(msgs)
+	if err != nil {
+		return err
+	}
+	return s.SendFrame("a" + string(b))
+}
+
+// Heartbeat queues a heartbeat frame
+func (s *Session) Heartbeat() error {
+	return s.SendFrame("h")
+}
+
+// Close queues a close frame with code and reason, ending the session once written
+func (s *Session) Close(code int, reason string) error {
+	b, err := json.Marshal([]interface{}{code, reason})
+	if err != nil {
+		return err
+	}
+	return s.SendFrame("c" + string(b))
+}
+
+// rotateFrame is queued by Rotate in place of a frame, ending the current streaming response
+const rotateFrame = "\x00rotate"
+
+// Rotate ends the current streaming response once frames queued before it are written,
+// as a server does once a response reaches its size limit, so the client must reopen
+// it to receive further frames. Ignored by the websocket and polling transports
+func (s *Session) Rotate() error {
+	return s.SendFrame(rotateFrame)
+}
+
+// Recv returns the next message sent by the client, giving up with ctx's error should
+// it be done first. Once the session has ended, queued messages are still returned
+// followed by ErrSessionClosed
+func (s *Session) Recv(ctx context.Context) (string, error) {
+	return s.in.pop(ctx, s.done)
+}
+
+// Done returns a channel that is closed once the session ends, i.e. a close frame was
+// written, the client closed its websocket or streaming request, or the server was closed
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
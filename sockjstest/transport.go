@@ -0,0 +1,282 @@
+package sockjstest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// upgrader upgrades websocket endpoint requests, from any origin
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// isClose returns whether frame is a close frame, ending its session once written
+func isClose(frame string) bool {
+	return strings.HasPrefix(frame, "c")
+}
+
+// callbackPattern matches valid jsonp and htmlfile callback names
+var callbackPattern = regexp.MustCompile(`^[a-zA-Z0-9_.]+$`)
+
+// callback returns the callback name of a jsonp or htmlfile request, responding
+// with an error (returning false) should it be missing or invalid
+func callback(w http.ResponseWriter, r *http.Request) (string, bool) {
+	c := r.URL.Query().Get("c")
+	if c == "" {
+		http.Error(w, `"callback" parameter required`, http.StatusInternalServerError)
+		return "", false
+	} else if !callbackPattern.MatchString(c) {
+		http.Error(w, `invalid "callback" parameter`, http.StatusInternalServerError)
+		return "", false
+	}
+	return c, true
+}
+
+// callbackArg returns frame encoded as a javascript string argument
+func callbackArg(frame string) string {
+	b, _ := json.Marshal(frame) // strings always marshal
+	return string(b)
+}
+
+// serveWebsocket serves the websocket endpoint, exchanging one frame per websocket message
+func (s *Server) serveWebsocket(w http.ResponseWriter, r *http.Request, id string) {
+	sess, opened := s.session(id, "websocket", r)
+	if !opened {
+		http.Error(w, "session already open", http.StatusConflict)
+		return
+	}
+
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		sess.end()
+		return
+	}
+	defer ws.Close()
+
+	// Receive client messages until closed
+	go func() {
+		defer sess.end()
+		for {
+			_, b, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			sess.receive(b) // invalid payloads are dropped
+		}
+	}()
+
+	if s.Open != "" {
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(s.Open)); err != nil {
+			sess.end()
+			return
+		}
+	}
+
+	// Write queued frames until session ends
+	for {
+		frame, err := sess.next(context.Background())
+		if err != nil {
+			return
+		} else if frame == rotateFrame {
+			continue // no stream to rotate
+		}
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			sess.end()
+			return
+		}
+		if isClose(frame) {
+			sess.end()
+			return
+		}
+	}
+}
+
+// servePoll serves a polling endpoint, responding to each poll with the next frame
+func (s *Server) servePoll(w http.ResponseWriter, r *http.Request, id, transport string, f framing) {
+	sess, opened := s.session(id, transport, r)
+	w.Header().Set("Content-Type", f.contentType)
+
+	// Open new session
+	if opened && s.Open != "" {
+		io.WriteString(w, f.format(s.Open)) // failure is seen by client
+		return
+	}
+
+	// Wait for next frame
+	frame, err := sess.next(r.Context())
+	for err == nil && frame == rotateFrame {
+		frame, err = sess.next(r.Context()) // no stream to rotate
+	}
+	if err == ErrSessionClosed {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		return // poll abandoned
+	}
+	io.WriteString(w, f.format(frame)) // failure is seen by client
+	if isClose(frame) {
+		sess.end()
+	}
+}
+
+// framing describes how a streaming or polling transport writes frames
+type framing struct {
+	contentType string
+	prelude     string
+	format      func(frame string) string
+}
+
+// Transport framings
+var (
+	pollFraming = framing{
+		contentType: "application/javascript; charset=UTF-8",
+		format:      func(frame string) string { return frame + "\n" },
+	}
+	streamFraming = framing{
+		contentType: "application/javascript; charset=UTF-8",
+		prelude:     strings.Repeat("h", 2048) + "\n",
+		format:      func(frame string) string { return frame + "\n" },
+	}
+	eventSourceFraming = framing{
+		contentType: "text/event-stream; charset=UTF-8",
+		prelude:     "\r\n",
+		format:      func(frame string) string { return "data: " + frame + "\r\n\r\n" },
+	}
+)
+
+// htmlFilePage is the htmlfile page header, padded to 1KiB before any frames
+const htmlFilePage = `<!doctype html>
+<html><head>
+  <meta http-equiv="X-UA-Compatible" content="IE=edge" />
+  <meta http-equiv="Content-Type" content="text/html; charset=UTF-8" />
+</head><body><h2>Don't panic!</h2>
+  <script>
+    document.domain = document.domain;
+    var c = parent.%s;
+    c.start();
+    function p(d) {c.message(d);};
+    window.onload = function() {c.stop();};
+  </script>
+`
+
+// htmlFileFraming returns the htmlfile framing, calling back to parent callback
+func htmlFileFraming(callback string) framing {
+	page := fmt.Sprintf(htmlFilePage, callback)
+	if len(page) < 1024 {
+		page += strings.Repeat(" ", 1024-len(page))
+	}
+	return framing{
+		contentType: "text/html; charset=UTF-8",
+		prelude:     page + "\r\n\r\n",
+		format:      func(frame string) string { return "<script>\np(" + callbackArg(frame) + ");\n</script>\r\n" },
+	}
+}
+
+// jsonpFraming returns the jsonp polling framing, calling callback
+func jsonpFraming(callback string) framing {
+	return framing{
+		contentType: "application/javascript; charset=UTF-8",
+		format:      func(frame string) string { return "/**/" + callback + "(" + callbackArg(frame) + ");\r\n" },
+	}
+}
+
+// serveStream serves a streaming endpoint, writing frames to a long-lived response
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, id, transport string, f framing) {
+	sess, opened := s.session(id, transport, r)
+	if !opened && sess.ended() {
+		http.NotFound(w, r)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", f.contentType)
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, f.prelude) // failure is seen by client
+	if opened && s.Open != "" {
+		io.WriteString(w, f.format(s.Open)) // failure is seen by client
+	}
+	flusher.Flush()
+
+	// Write queued frames until session ends, or client abandons stream
+	for {
+		frame, err := sess.next(r.Context())
+		if err == ErrSessionClosed {
+			return
+		} else if err != nil {
+			sess.end()
+			return
+		} else if frame == rotateFrame {
+			return // client to reopen
+		}
+		if _, err := io.WriteString(w, f.format(frame)); err != nil {
+			sess.end()
+			return
+		}
+		flusher.Flush()
+		if isClose(frame) {
+			sess.end()
+			return
+		}
+	}
+}
+
+// serveSend serves the xhr_send endpoint, receiving a block of client messages
+func (s *Server) serveSend(w http.ResponseWriter, r *http.Request, id string) {
+	sess := s.Session(id)
+	if sess == nil || sess.ended() {
+		http.NotFound(w, r)
+		return
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if err := sess.receive(b); err != nil {
+		http.Error(w, "Broken JSON encoding.", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveJSONPSend serves the jsonp_send endpoint, receiving a block of client messages
+// form-encoded as field d, or as the raw body
+func (s *Server) serveJSONPSend(w http.ResponseWriter, r *http.Request, id string) {
+	sess := s.Session(id)
+	if sess == nil || sess.ended() {
+		http.NotFound(w, r)
+		return
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(b))
+		if err != nil || form.Get("d") == "" {
+			http.Error(w, "Payload expected.", http.StatusInternalServerError)
+			return
+		}
+		b = []byte(form.Get("d"))
+	}
+	if err := sess.receive(b); err != nil {
+		http.Error(w, "Broken JSON encoding.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	io.WriteString(w, "ok") // failure is seen by client
+}
@@ -0,0 +1,22 @@
+package sockjsclient
+
+import "time"
+
+// Stats holds statistics for a sockjs connection
+type Stats struct {
+	// PingsSent is the number of websocket keepalive pings sent
+	PingsSent uint64
+
+	// PongsReceived is the number of websocket keepalive pongs received
+	PongsReceived uint64
+
+	// RTT is the most recently measured websocket ping round trip time, zero if none
+	RTT time.Duration
+
+	// LastPong is the time the most recent websocket pong was received
+	LastPong time.Time
+
+	// MessagesDropped is the number of inbound messages discarded due to
+	// a full inbound buffer, according to the conn's OverflowPolicy
+	MessagesDropped uint64
+}
@@ -0,0 +1,510 @@
+// Package stomp implements a STOMP 1.1 / 1.2 client over a sockjs Client
+package stomp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/internal/layer"
+)
+
+// STOMP client error messages
+var (
+	ErrNotConnected    = errors.New("stomp: client not connected")
+	ErrUnexpectedFrame = errors.New("stomp: unexpected frame")
+	ErrReceiptLost     = errors.New("stomp: session lost awaiting receipt")
+)
+
+// acceptVersion is the list of STOMP protocol versions supported
+const acceptVersion = "1.1,1.2"
+
+// ServerError is returned on receiving an ERROR frame from the server
+type ServerError struct {
+	Frame *Frame
+}
+
+func (e *ServerError) Error() string {
+	if msg := e.Frame.Get("message"); msg != "" {
+		return "stomp: server error: " + msg
+	}
+	return "stomp: server error"
+}
+
+// Client is a STOMP client layered over a sockjs Client, resuming the STOMP session and
+// all subscriptions whenever the sockjs client reconnects (see sockjsclient.Client.Reconnect)
+type Client struct {
+	// SockJS is the sockjs client carrying STOMP frames, configured but not yet
+	// connected. Connect adds an event hook to it, to resume the session on reconnect
+	SockJS *sockjsclient.Client
+
+	// Host is the virtual host to connect to. Defaults to the sockjs address host
+	Host string
+
+	// Login and Passcode are the optional credentials to connect with
+	Login    string
+	Passcode string
+
+	// Header holds any additional headers to send with CONNECT frames
+	Header Header
+
+	// HeartBeatSend and HeartBeatReceive are the intervals at which this client
+	// offers to send heart-beats, and wants to receive them. Zero disables each
+	HeartBeatSend    time.Duration
+	HeartBeatReceive time.Duration
+
+	// OnError is called with any ERROR frame received from the server. The
+	// server then closes the connection
+	OnError func(*Frame)
+
+	lastRead  int64 // time of last frame read, in unix nanos, accessed atomically
+	lastWrite int64 // time of last frame written, in unix nanos, accessed atomically
+	nextID    int64 // last generated id, accessed atomically
+
+	version  string                   // negotiated protocol version
+	out      time.Duration            // negotiated outgoing heart-beat interval
+	in       time.Duration            // negotiated incoming heart-beat interval
+	subs     map[string]*Subscription // active subscriptions by id
+	receipts map[string]chan error    // receipt waiters by receipt id
+	life     layer.Lifecycle          // read loop lifecycle
+	mu       sync.Mutex               // protects version, out, in, subs, receipts
+}
+
+func (c *Client) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext connects the sockjs client and performs the STOMP handshake,
+// giving up with ctx's error should it be done first
+func (c *Client) ConnectContext(ctx context.Context) error {
+	// Resume session on each reconnect, receipts awaited being lost with the previous
+	c.life.Hook(c.SockJS, func(ev sockjsclient.Event) {
+		if ev.Type == sockjsclient.EventReconnected {
+			c.failReceipts()
+			go c.resume()
+		}
+	})
+
+	// Connect underlying sockjs client
+	if err := c.SockJS.ConnectContext(ctx); err != nil {
+		return err
+	}
+
+	// Send CONNECT, awaiting CONNECTED
+	if err := c.write(ctx, c.connectFrame()); err != nil {
+		c.SockJS.Close()
+		return err
+	}
+	f, err := c.readConnected(ctx)
+	if err != nil {
+		c.SockJS.Close()
+		return err
+	}
+
+	// Set up new session
+	c.mu.Lock()
+	c.subs = map[string]*Subscription{}
+	c.receipts = map[string]chan error{}
+	run := c.life.Start()
+	c.mu.Unlock()
+	if err := c.connected(f); err != nil {
+		c.SockJS.Close()
+		run.Stop(err)
+		return err
+	}
+
+	go c.readLoop(run)
+	go c.heartBeatLoop(run.Done())
+
+	return nil
+}
+
+// connectFrame returns the CONNECT frame to open (or resume) a session with
+func (c *Client) connectFrame() *Frame {
+	f := NewFrame(CommandConnect,
+		"accept-version", acceptVersion,
+		"host", c.host(),
+		"heart-beat", formatHeartBeat(c.HeartBeatSend, c.HeartBeatReceive),
+	)
+	for key, value := range c.Header {
+		f.Set(key, value)
+	}
+	if c.Login != "" {
+		f.Set("login", c.Login)
+		f.Set("passcode", c.Passcode)
+	}
+	return f
+}
+
+// host returns the virtual host to connect to
+func (c *Client) host() string {
+	if c.Host != "" {
+		return c.Host
+	}
+	u, err := url.Parse(c.SockJS.Address)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// readConnected reads frames until a CONNECTED frame is received
+func (c *Client) readConnected(ctx context.Context) (*Frame, error) {
+	for {
+		b, err := c.SockJS.ReadMsgContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		frames, err := ParseFrames(b)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range frames {
+			switch f.Command {
+			case CommandConnected:
+				return f, nil
+			case CommandError:
+				return nil, &ServerError{Frame: f}
+			default:
+				return nil, fmt.Errorf("%w: %s awaiting %s", ErrUnexpectedFrame, f.Command, CommandConnected)
+			}
+		}
+	}
+}
+
+// connected records the negotiated session parameters from a CONNECTED frame
+func (c *Client) connected(f *Frame) error {
+	sx, sy, err := parseHeartBeat(f.Get("heart-beat"))
+	if err != nil {
+		return err
+	}
+	out, in := negotiateHeartBeat(c.HeartBeatSend, c.HeartBeatReceive, sx, sy)
+
+	version := f.Get("version")
+	if version == "" {
+		version = "1.0"
+	}
+
+	c.mu.Lock()
+	c.version = version
+	c.out = out
+	c.in = in
+	c.mu.Unlock()
+
+	atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+	return nil
+}
+
+// resume sends a CONNECT frame over a newly reconnected sockjs conn. Subscriptions are
+// then resumed by the read loop on receiving CONNECTED
+func (c *Client) resume() {
+	atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+	c.write(context.Background(), c.connectFrame()) // failure is seen by read loop
+}
+
+// failReceipts fails the receipt waiters of a session ended by reconnect with ErrReceiptLost
+func (c *Client) failReceipts() {
+	c.mu.Lock()
+	receipts := c.receipts
+	if receipts != nil {
+		c.receipts = map[string]chan error{}
+	}
+	c.mu.Unlock()
+	for _, ch := range receipts {
+		ch <- ErrReceiptLost // buffered
+	}
+}
+
+// resubscribe sends a SUBSCRIBE frame for each active subscription
+func (c *Client) resubscribe() {
+	c.mu.Lock()
+	subs := make([]*Subscription, 0, len(c.subs))
+	for _, sub := range c.subs {
+		subs = append(subs, sub)
+	}
+	c.mu.Unlock()
+
+	for _, sub := range subs {
+		c.write(context.Background(), sub.frame()) // failure is seen by read loop
+	}
+}
+
+// readLoop reads and dispatches frames until the sockjs client fails or is closed
+func (c *Client) readLoop(run *layer.Run) {
+	run.Stop(c.dispatch())
+}
+
+// dispatch reads frames, passing each to its handler, until an error occurs
+func (c *Client) dispatch() error {
+	for {
+		b, err := c.SockJS.ReadMsg()
+		if err != nil {
+			return err
+		}
+		atomic.StoreInt64(&c.lastRead, time.Now().UnixNano())
+
+		frames, err := ParseFrames(b)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range frames {
+			switch f.Command {
+			// Pass to subscription handler
+			case CommandMessage:
+				c.mu.Lock()
+				sub := c.subs[f.Get("subscription")]
+				c.mu.Unlock()
+				if sub != nil {
+					sub.handler(&Message{Frame: f, Subscription: sub})
+				}
+
+			// Signal receipt waiter
+			case CommandReceipt:
+				c.mu.Lock()
+				ch := c.receipts[f.Get("receipt-id")]
+				delete(c.receipts, f.Get("receipt-id"))
+				c.mu.Unlock()
+				if ch != nil {
+					ch <- nil // buffered
+				}
+
+			// Session resumed after reconnect
+			case CommandConnected:
+				if err := c.connected(f); err != nil {
+					return err
+				}
+				c.resubscribe()
+
+			// Server will now close the connection
+			case CommandError:
+				if c.OnError != nil {
+					c.OnError(f)
+				}
+				return &ServerError{Frame: f}
+			}
+		}
+	}
+}
+
+// heartBeatLoop sends heart-beats and checks for those received, as negotiated, until done
+func (c *Client) heartBeatLoop(done <-chan struct{}) {
+	timer := time.NewTimer(c.heartBeatTick())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+
+		c.mu.Lock()
+		out, in := c.out, c.in
+		c.mu.Unlock()
+		now := time.Now()
+
+		// Send heart-beat if idle
+		if out > 0 && now.Sub(time.Unix(0, atomic.LoadInt64(&c.lastWrite))) >= out {
+			c.writeHeartBeat()
+		}
+
+		// Drop conn if heart-beats missed, leaving sockjs client to reconnect
+		if in > 0 && now.Sub(time.Unix(0, atomic.LoadInt64(&c.lastRead))) > 2*in {
+			if conn := c.SockJS.Conn(); conn != nil {
+				conn.Close()
+			}
+			atomic.StoreInt64(&c.lastRead, now.UnixNano())
+		}
+
+		timer.Reset(c.heartBeatTick())
+	}
+}
+
+// heartBeatTick returns the interval at which to check heart-beats, half the smallest negotiated
+func (c *Client) heartBeatTick() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tick := time.Second
+	if c.out > 0 && c.out/2 < tick {
+		tick = c.out / 2
+	}
+	if c.in > 0 && c.in/2 < tick {
+		tick = c.in / 2
+	}
+	return tick
+}
+
+// writeHeartBeat sends a heart-beat EOL
+func (c *Client) writeHeartBeat() {
+	atomic.StoreInt64(&c.lastWrite, time.Now().UnixNano())
+	c.SockJS.WriteMsg([]byte{'\n'}) // failure is seen by read loop
+}
+
+// write sends frame to the server
+func (c *Client) write(ctx context.Context, f *Frame) error {
+	c.mu.Lock()
+	version := c.version
+	c.mu.Unlock()
+	atomic.StoreInt64(&c.lastWrite, time.Now().UnixNano())
+	return c.SockJS.WriteMsgContext(ctx, f.encode(version))
+}
+
+// newID returns a new id unique to this client, prefixed with prefix
+func (c *Client) newID(prefix string) string {
+	return prefix + "-" + strconv.FormatInt(atomic.AddInt64(&c.nextID, 1), 10)
+}
+
+// Write sends frame to the server
+func (c *Client) Write(f *Frame) error {
+	return c.write(context.Background(), f)
+}
+
+// WriteReceipt sends frame to the server requesting a receipt, then waits until it is
+// received, ctx is done or the client fails. It must not be called from a subscription handler
+func (c *Client) WriteReceipt(ctx context.Context, f *Frame) error {
+	// Register receipt waiter
+	id := c.newID("receipt")
+	ch := make(chan error, 1)
+	c.mu.Lock()
+	if c.receipts == nil {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	c.receipts[id] = ch
+	done := c.life.Done()
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.receipts, id)
+		c.mu.Unlock()
+	}()
+
+	// Send frame with receipt header
+	f.Set("receipt", id)
+	if err := c.write(ctx, f); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-ch:
+		return err
+	case <-done:
+		return c.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Send sends body to destination, with content type if set
+func (c *Client) Send(destination, contentType string, body []byte) error {
+	return c.Write(sendFrame(destination, contentType, body))
+}
+
+// sendFrame returns a SEND frame for body to destination
+func sendFrame(destination, contentType string, body []byte) *Frame {
+	f := NewFrame(CommandSend, "destination", destination)
+	if contentType != "" {
+		f.Set("content-type", contentType)
+	}
+	f.Body = body
+	return f
+}
+
+// Subscribe subscribes to destination, passing each received message to handler. Handlers are
+// called from the client's read goroutine so must not block, and with ack mode AckClient or
+// AckClientIndividual must acknowledge messages using Message.Ack / Message.Nack
+func (c *Client) Subscribe(destination string, ack AckMode, handler func(*Message)) (*Subscription, error) {
+	if ack == "" {
+		ack = AckAuto
+	}
+	sub := &Subscription{
+		ID:          c.newID("sub"),
+		Destination: destination,
+		Ack:         ack,
+		client:      c,
+		handler:     handler,
+	}
+
+	// Register before subscribing, to catch first messages
+	c.mu.Lock()
+	if c.subs == nil {
+		c.mu.Unlock()
+		return nil, ErrNotConnected
+	}
+	c.subs[sub.ID] = sub
+	c.mu.Unlock()
+
+	if err := c.Write(sub.frame()); err != nil {
+		c.mu.Lock()
+		delete(c.subs, sub.ID)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// Begin starts a new transaction
+func (c *Client) Begin() (*Transaction, error) {
+	tx := &Transaction{ID: c.newID("tx"), client: c}
+	if err := c.Write(NewFrame(CommandBegin, "transaction", tx.ID)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// ackFrame returns an ACK or NACK frame for msg, within transaction if set
+func (c *Client) ackFrame(command string, msg *Message, transaction string) *Frame {
+	c.mu.Lock()
+	version := c.version
+	c.mu.Unlock()
+
+	var f *Frame
+	if version == "1.2" {
+		f = NewFrame(command, "id", msg.Get("ack"))
+	} else {
+		f = NewFrame(command,
+			"message-id", msg.Get("message-id"),
+			"subscription", msg.Get("subscription"),
+		)
+	}
+	if transaction != "" {
+		f.Set("transaction", transaction)
+	}
+	return f
+}
+
+// Done returns a channel that is closed once the client stops reading frames, i.e.
+// the sockjs client was closed or lost for good, or an ERROR frame was received
+func (c *Client) Done() <-chan struct{} {
+	return c.life.Done()
+}
+
+// Err returns the error that stopped the client reading frames, nil until Done is
+// closed, or ErrNotConnected if never connected
+func (c *Client) Err() error {
+	return c.life.Err(ErrNotConnected)
+}
+
+// Disconnect gracefully ends the STOMP session, awaiting the server's receipt
+// (or ctx being done) before closing the sockjs client
+func (c *Client) Disconnect(ctx context.Context) error {
+	err := c.WriteReceipt(ctx, NewFrame(CommandDisconnect))
+	if cerr := c.SockJS.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Close closes the sockjs client without ending the STOMP session gracefully
+func (c *Client) Close() error {
+	return c.SockJS.Close()
+}
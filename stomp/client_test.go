@@ -0,0 +1,255 @@
+package stomp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestClientConnect(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{
+		SockJS:           newTestSockJS(srv, nil),
+		Login:            "guest",
+		Passcode:         "secret",
+		HeartBeatReceive: time.Second,
+	}
+	session, f, connected := startTestClient(t, srv, c)
+	defer c.Close()
+
+	// CONNECT offers versions, vhost, credentials and heart-beats
+	if f.Command != CommandConnect || f.Get("accept-version") != acceptVersion || f.Get("host") != "127.0.0.1" ||
+		f.Get("login") != "guest" || f.Get("passcode") != "secret" || f.Get("heart-beat") != "0,1000" {
+		t.Fatalf("connect frame was not as expected: %+v", f)
+	}
+
+	// CONNECTED negotiates them
+	connectTestClient(t, session, connected, "version", "1.2", "heart-beat", "2000,0")
+	c.mu.Lock()
+	version, out, in := c.version, c.out, c.in
+	c.mu.Unlock()
+	if version != "1.2" || out != 0 || in != time.Second*2 {
+		t.Fatalf("negotiated session was not as expected: {Version=%s Out=%v In=%v}", version, out, in)
+	}
+}
+
+func TestClientConnectError(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{SockJS: newTestSockJS(srv, nil)}
+	session, _, connected := startTestClient(t, srv, c)
+
+	session.Send(string(NewFrame(CommandError, "message", "bad login").Bytes()))
+	var serr *ServerError
+	if err := <-connected; !errors.As(err, &serr) || serr.Frame.Get("message") != "bad login" {
+		t.Fatalf("expected server error connecting, got %v", err)
+	}
+	if err := c.Err(); err != ErrNotConnected {
+		t.Fatalf("expected ErrNotConnected after failed connect, got %v", err)
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{SockJS: newTestSockJS(srv, nil)}
+	session, _, connected := startTestClient(t, srv, c)
+	defer c.Close()
+	connectTestClient(t, session, connected, "version", "1.2")
+
+	msgs := make(chan *Message, 1)
+	sub, err := c.Subscribe("/topic/a", "", func(msg *Message) { msgs <- msg })
+	if err != nil {
+		t.Fatalf("error subscribing: %v", err)
+	}
+	if f := recvTestFrame(t, session); f.Command != CommandSubscribe || f.Get("id") != sub.ID ||
+		f.Get("destination") != "/topic/a" || f.Get("ack") != string(AckAuto) {
+		t.Fatalf("subscribe frame was not as expected: %+v", f)
+	}
+
+	// Messages passed to subscription handler, others ignored
+	for _, id := range []string{"sub-other", sub.ID} {
+		f := NewFrame(CommandMessage, "subscription", id, "destination", "/topic/a", "message-id", "1")
+		f.Body = []byte("hello " + id)
+		session.Send(string(f.Bytes()))
+	}
+	select {
+	case msg := <-msgs:
+		if msg.Subscription != sub || msg.Destination() != "/topic/a" || string(msg.Body) != "hello "+sub.ID {
+			t.Fatalf("message was not as expected: %+v", msg.Frame)
+		}
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for message")
+	}
+}
+
+func TestClientWriteReceipt(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{SockJS: newTestSockJS(srv, nil)}
+	session, _, connected := startTestClient(t, srv, c)
+	defer c.Close()
+	connectTestClient(t, session, connected, "version", "1.2")
+
+	received := make(chan error, 1)
+	go func() { received <- c.WriteReceipt(testContext(t), sendFrame("/queue/a", "text/plain", []byte("hi"))) }()
+	f := recvTestFrame(t, session)
+	if f.Command != CommandSend || f.Get("receipt") == "" || string(f.Body) != "hi" {
+		t.Fatalf("send frame was not as expected: %+v", f)
+	}
+
+	// Other receipts do not complete the wait
+	session.Send(string(NewFrame(CommandReceipt, "receipt-id", "receipt-other").Bytes()))
+	select {
+	case err := <-received:
+		t.Fatalf("expected wait for own receipt, got %v", err)
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	session.Send(string(NewFrame(CommandReceipt, "receipt-id", f.Get("receipt")).Bytes()))
+	if err := <-received; err != nil {
+		t.Fatalf("error awaiting receipt: %v", err)
+	}
+}
+
+func TestClientWriteReceiptReconnect(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{SockJS: newTestSockJS(srv, &sockjsclient.ReconnectPolicy{InitialDelay: time.Millisecond})}
+	session, _, connected := startTestClient(t, srv, c)
+	defer c.Close()
+	connectTestClient(t, session, connected, "version", "1.2")
+
+	received := make(chan error, 1)
+	go func() { received <- c.WriteReceipt(testContext(t), sendFrame("/queue/a", "text/plain", []byte("hi"))) }()
+	recvTestFrame(t, session)
+
+	// Receipt lost with the session once reconnected
+	session.Close(1002, "Connection interrupted")
+	if _, err := srv.Accept(testContext(t)); err != nil {
+		t.Fatalf("error accepting reconnected session: %v", err)
+	}
+	select {
+	case err := <-received:
+		if err != ErrReceiptLost {
+			t.Fatalf("expected ErrReceiptLost, got %v", err)
+		}
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for receipt wait to fail")
+	}
+}
+
+func TestClientHeartBeatTimeout(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{SockJS: newTestSockJS(srv, nil), HeartBeatReceive: time.Millisecond * 10}
+	session, _, connected := startTestClient(t, srv, c)
+	defer c.Close()
+	connectTestClient(t, session, connected, "version", "1.2", "heart-beat", "10,0")
+
+	// Conn dropped once heart-beats missed, ending the client without reconnect
+	select {
+	case <-c.Done():
+	case <-testContext(t).Done():
+		t.Fatal("timed out waiting for heart-beat timeout")
+	}
+	if err := c.Err(); err == nil {
+		t.Fatal("expected error once heart-beats missed")
+	}
+}
+
+func TestClientResubscribe(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	c := &Client{SockJS: newTestSockJS(srv, &sockjsclient.ReconnectPolicy{InitialDelay: time.Millisecond})}
+	session, _, connected := startTestClient(t, srv, c)
+	defer c.Close()
+	connectTestClient(t, session, connected, "version", "1.2")
+
+	sub, err := c.Subscribe("/topic/a", AckClient, func(*Message) {})
+	if err != nil {
+		t.Fatalf("error subscribing: %v", err)
+	}
+	recvTestFrame(t, session)
+
+	// Session and subscriptions resumed once reconnected
+	session.Close(1002, "Connection interrupted")
+	resumed, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting reconnected session: %v", err)
+	}
+	if f := recvTestFrame(t, resumed); f.Command != CommandConnect {
+		t.Fatalf("expected connect frame on reconnect, got %+v", f)
+	}
+	resumed.Send(string(NewFrame(CommandConnected, "version", "1.2").Bytes()))
+	if f := recvTestFrame(t, resumed); f.Command != CommandSubscribe || f.Get("id") != sub.ID ||
+		f.Get("destination") != "/topic/a" || f.Get("ack") != string(AckClient) {
+		t.Fatalf("resubscribe frame was not as expected: %+v", f)
+	}
+}
+
+// newTestSockJS returns a sockjs client for srv, reconnecting according to reconnect if set
+func newTestSockJS(srv *sockjstest.Server, reconnect *sockjsclient.ReconnectPolicy) *sockjsclient.Client {
+	return &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+		Reconnect:  reconnect,
+	}
+}
+
+// startTestClient starts connecting c, returning its accepted session, the CONNECT frame
+// received and a channel passed the connect result
+func startTestClient(t *testing.T, srv *sockjstest.Server, c *Client) (*sockjstest.Session, *Frame, <-chan error) {
+	connected := make(chan error, 1)
+	go func() { connected <- c.ConnectContext(testContext(t)) }()
+
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+	return session, recvTestFrame(t, session), connected
+}
+
+// connectTestClient completes a connect started by startTestClient, sending a CONNECTED
+// frame with kv headers over session
+func connectTestClient(t *testing.T, session *sockjstest.Session, connected <-chan error, kv ...string) {
+	session.Send(string(NewFrame(CommandConnected, kv...).Bytes()))
+	if err := <-connected; err != nil {
+		t.Fatalf("error connecting stomp client: %v", err)
+	}
+}
+
+// recvTestFrame receives the next frame sent by the client over session, skipping heart-beats
+func recvTestFrame(t *testing.T, session *sockjstest.Session) *Frame {
+	for {
+		msg, err := session.Recv(testContext(t))
+		if err != nil {
+			t.Fatalf("error receiving frame from client: %v", err)
+		}
+		frames, err := ParseFrames([]byte(msg))
+		if err != nil {
+			t.Fatalf("error parsing frame from client: %v", err)
+		} else if len(frames) > 0 {
+			return frames[0]
+		}
+	}
+}
+
+// testContext returns a context bounding a single test step
+func testContext(t *testing.T) context.Context {
+	ctx, cncl := context.WithTimeout(context.Background(), time.Second*5)
+	t.Cleanup(cncl)
+	return ctx
+}
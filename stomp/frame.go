@@ -0,0 +1,261 @@
+package stomp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// STOMP frame commands
+const (
+	CommandConnect     = "CONNECT"
+	CommandConnected   = "CONNECTED"
+	CommandSend        = "SEND"
+	CommandSubscribe   = "SUBSCRIBE"
+	CommandUnsubscribe = "UNSUBSCRIBE"
+	CommandAck         = "ACK"
+	CommandNack        = "NACK"
+	CommandBegin       = "BEGIN"
+	CommandCommit      = "COMMIT"
+	CommandAbort       = "ABORT"
+	CommandDisconnect  = "DISCONNECT"
+	CommandMessage     = "MESSAGE"
+	CommandReceipt     = "RECEIPT"
+	CommandError       = "ERROR"
+)
+
+// ErrInvalidFrame is returned on failing to parse a STOMP frame
+var ErrInvalidFrame = errors.New("stomp: invalid frame")
+
+// Header holds STOMP frame headers. Where a header is repeated
+// within a received frame, only the first value is kept
+type Header map[string]string
+
+// Frame represents a single STOMP frame
+type Frame struct {
+	Command string
+	Header  Header
+	Body    []byte
+}
+
+// NewFrame returns a new frame for command with headers taken from alternating key, value pairs
+func NewFrame(command string, kv ...string) *Frame {
+	f := &Frame{Command: command, Header: Header{}}
+	for i := 0; i+1 < len(kv); i += 2 {
+		f.Header[kv[i]] = kv[i+1]
+	}
+	return f
+}
+
+// Get returns the value of header key, empty if not set
+func (f *Frame) Get(key string) string {
+	return f.Header[key]
+}
+
+// Set sets header key to value
+func (f *Frame) Set(key, value string) {
+	if f.Header == nil {
+		f.Header = Header{}
+	}
+	f.Header[key] = value
+}
+
+// Bytes returns the frame encoded for the wire as per STOMP 1.2, NUL terminated. Headers are
+// written in sorted order, and content-length is added for any body where not already set
+func (f *Frame) Bytes() []byte {
+	return f.encode("1.2")
+}
+
+// encode returns the frame encoded for the wire under the negotiated protocol version, as Bytes
+// but only escaping carriage returns in headers from STOMP 1.2 on
+func (f *Frame) encode(version string) []byte {
+	var buf bytes.Buffer
+
+	// Write command line
+	buf.WriteString(f.Command)
+	buf.WriteByte('\n')
+
+	// Write header lines, escaping all but connect frames
+	escape := f.Command != CommandConnect && f.Command != CommandConnected
+	keys := make([]string, 0, len(f.Header)+1)
+	for key := range f.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := f.Header[key]
+		if escape {
+			key, value = escapeHeader(key, version), escapeHeader(value, version)
+		}
+		buf.WriteString(key)
+		buf.WriteByte(':')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+	}
+	if _, ok := f.Header["content-length"]; !ok && len(f.Body) > 0 {
+		buf.WriteString("content-length:")
+		buf.WriteString(strconv.Itoa(len(f.Body)))
+		buf.WriteByte('\n')
+	}
+
+	// Write body and terminator
+	buf.WriteByte('\n')
+	buf.Write(f.Body)
+	buf.WriteByte(0)
+
+	return buf.Bytes()
+}
+
+// ParseFrames parses all STOMP frames from data, as received in a single sockjs message.
+// Heart-beat EOLs are skipped, so a message holding only a heart-beat yields no frames
+func ParseFrames(data []byte) ([]*Frame, error) {
+	var frames []*Frame
+	for {
+		// Skip heart-beats before next frame
+		data = bytes.TrimLeft(data, "\r\n")
+		if len(data) == 0 {
+			return frames, nil
+		}
+
+		// Parse next frame
+		f, rest, err := parseFrame(data)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, f)
+		data = rest
+	}
+}
+
+// parseFrame parses a single frame from the start of data, returning the remaining data
+func parseFrame(data []byte) (*Frame, []byte, error) {
+	// Read command line
+	line, data, ok := nextLine(data)
+	if !ok || line == "" {
+		return nil, nil, fmt.Errorf("%w: missing command", ErrInvalidFrame)
+	}
+	f := &Frame{Command: line, Header: Header{}}
+	unescape := f.Command != CommandConnect && f.Command != CommandConnected
+
+	// Read header lines until blank line
+	for {
+		line, data, ok = nextLine(data)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: unterminated headers", ErrInvalidFrame)
+		} else if line == "" {
+			break
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			return nil, nil, fmt.Errorf("%w: malformed header %q", ErrInvalidFrame, line)
+		}
+		key, value := line[:i], line[i+1:]
+		if unescape {
+			var err error
+			if key, err = unescapeHeader(key); err != nil {
+				return nil, nil, err
+			} else if value, err = unescapeHeader(value); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		// First occurrence of a header wins
+		if _, ok := f.Header[key]; !ok {
+			f.Header[key] = value
+		}
+	}
+
+	// Read body, by content-length if set, else up to NUL
+	n := bytes.IndexByte(data, 0)
+	if cl, ok := f.Header["content-length"]; ok {
+		length, err := strconv.Atoi(cl)
+		if err != nil || length < 0 {
+			return nil, nil, fmt.Errorf("%w: bad content-length %q", ErrInvalidFrame, cl)
+		}
+		if length >= len(data) || data[length] != 0 {
+			return nil, nil, fmt.Errorf("%w: body does not match content-length", ErrInvalidFrame)
+		}
+		n = length
+	}
+	if n < 0 {
+		return nil, nil, fmt.Errorf("%w: missing NUL terminator", ErrInvalidFrame)
+	}
+	if n > 0 {
+		f.Body = data[:n]
+	}
+
+	return f, data[n+1:], nil
+}
+
+// nextLine returns the next EOL terminated line of data (without EOL) and the remaining data
+func nextLine(data []byte) (string, []byte, bool) {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return "", data, false
+	}
+	line := data[:i]
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	return string(line), data[i+1:], true
+}
+
+// headerEscaper escapes STOMP 1.2 header keys and values
+var headerEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\r", "\\r",
+	"\n", "\\n",
+	":", "\\c",
+)
+
+// headerEscaper11 escapes STOMP 1.1 header keys and values, which have no carriage return escape
+var headerEscaper11 = strings.NewReplacer(
+	"\\", "\\\\",
+	"\n", "\\n",
+	":", "\\c",
+)
+
+// escapeHeader returns s with special characters escaped as per the given STOMP version
+func escapeHeader(s, version string) string {
+	if version == "1.2" {
+		return headerEscaper.Replace(s)
+	}
+	return headerEscaper11.Replace(s)
+}
+
+// unescapeHeader returns s with STOMP 1.2 escape sequences decoded
+func unescapeHeader(s string) (string, error) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		// Decode escape sequence
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("%w: bad header escape in %q", ErrInvalidFrame, s)
+		}
+		switch s[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		case 'c':
+			b.WriteByte(':')
+		default:
+			return "", fmt.Errorf("%w: bad header escape in %q", ErrInvalidFrame, s)
+		}
+	}
+
+	return b.String(), nil
+}
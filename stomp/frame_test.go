@@ -0,0 +1,109 @@
+package stomp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFrameBytes(t *testing.T) {
+	f := NewFrame(CommandSend, "destination", "/queue/a:b", "note", "line\nbreak")
+	f.Body = []byte("hello")
+
+	exp := "SEND\ndestination:/queue/a\\cb\nnote:line\\nbreak\ncontent-length:5\n\nhello\x00"
+	if got := string(f.Bytes()); got != exp {
+		t.Fatalf("encoded frame was not as expected: {Expect=%q Got=%q}", exp, got)
+	}
+
+	// Carriage returns only escaped as of STOMP 1.2
+	f = NewFrame(CommandSend, "note", "a\r\nb")
+	if got, exp := string(f.encode("1.2")), "SEND\nnote:a\\r\\nb\n\n\x00"; got != exp {
+		t.Fatalf("encoded 1.2 frame was not as expected: {Expect=%q Got=%q}", exp, got)
+	} else if got, exp := string(f.encode("1.1")), "SEND\nnote:a\r\\nb\n\n\x00"; got != exp {
+		t.Fatalf("encoded 1.1 frame was not as expected: {Expect=%q Got=%q}", exp, got)
+	}
+
+	// Connect frames are not escaped
+	f = NewFrame(CommandConnect, "login", "a:b")
+	exp = "CONNECT\nlogin:a:b\n\n\x00"
+	if got := string(f.Bytes()); got != exp {
+		t.Fatalf("encoded connect frame was not as expected: {Expect=%q Got=%q}", exp, got)
+	}
+}
+
+func TestParseFrames(t *testing.T) {
+	data := "\n\r\nMESSAGE\r\nsubscription:sub-1\ndestination:/topic/a\\cb\ndestination:ignored\n\nfirst\x00" +
+		"\nMESSAGE\ncontent-length:3\n\na\x00b\x00\n"
+
+	frames, err := ParseFrames([]byte(data))
+	if err != nil {
+		t.Fatalf("error parsing frames: %v", err)
+	} else if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+
+	if f := frames[0]; f.Command != CommandMessage || f.Get("destination") != "/topic/a:b" || string(f.Body) != "first" {
+		t.Fatalf("first frame was not as expected: %+v", f)
+	}
+	if f := frames[1]; !bytes.Equal(f.Body, []byte("a\x00b")) {
+		t.Fatalf("second frame body was not as expected: %q", f.Body)
+	}
+
+	// Heart-beats only
+	if frames, err := ParseFrames([]byte("\n")); err != nil || len(frames) != 0 {
+		t.Fatalf("expected no frames for heart-beat, got %d (err=%v)", len(frames), err)
+	}
+}
+
+func TestParseFramesInvalid(t *testing.T) {
+	for _, data := range []string{
+		"SEND\ndestination:/a\n\nbody",
+		"SEND\ndestination\n\n\x00",
+		"SEND\nbad:esc\\x\n\n\x00",
+		"SEND\ncontent-length:10\n\nshort\x00",
+		"SEND\n",
+	} {
+		if _, err := ParseFrames([]byte(data)); !errors.Is(err, ErrInvalidFrame) {
+			t.Fatalf("expected ErrInvalidFrame parsing %q, got %v", data, err)
+		}
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	f := NewFrame(CommandMessage, "key:\\", "value\r\n:")
+	f.Body = []byte(`{"a":1}`)
+
+	frames, err := ParseFrames(f.Bytes())
+	if err != nil {
+		t.Fatalf("error parsing frame: %v", err)
+	}
+	if got := frames[0]; got.Get("key:\\") != "value\r\n:" || !bytes.Equal(got.Body, f.Body) {
+		t.Fatalf("round tripped frame was not as expected: %+v", got)
+	}
+}
+
+func TestNegotiateHeartBeat(t *testing.T) {
+	sx, sy, err := parseHeartBeat("5000,1000")
+	if err != nil {
+		t.Fatalf("error parsing heart-beat: %v", err)
+	}
+
+	for _, tc := range []struct {
+		cx, cy  time.Duration
+		out, in time.Duration
+	}{
+		{cx: 0, cy: 0, out: 0, in: 0},
+		{cx: 2 * time.Second, cy: 0, out: 2 * time.Second, in: 0},
+		{cx: 500 * time.Millisecond, cy: 10 * time.Second, out: time.Second, in: 10 * time.Second},
+	} {
+		out, in := negotiateHeartBeat(tc.cx, tc.cy, sx, sy)
+		if out != tc.out || in != tc.in {
+			t.Fatalf("negotiated heart-beat was not as expected: {Expect=%v,%v Got=%v,%v}", tc.out, tc.in, out, in)
+		}
+	}
+
+	if _, _, err := parseHeartBeat("1,2,3"); !errors.Is(err, ErrInvalidFrame) {
+		t.Fatalf("expected ErrInvalidFrame parsing bad heart-beat, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package stomp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formatHeartBeat formats a heart-beat header value from send and receive intervals
+func formatHeartBeat(send, receive time.Duration) string {
+	return fmt.Sprintf("%d,%d", send.Milliseconds(), receive.Milliseconds())
+}
+
+// parseHeartBeat parses send and receive intervals from a heart-beat header value,
+// both zero if empty
+func parseHeartBeat(s string) (time.Duration, time.Duration, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%w: bad heart-beat %q", ErrInvalidFrame, s)
+	}
+	send, err1 := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 32)
+	receive, err2 := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 32)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("%w: bad heart-beat %q", ErrInvalidFrame, s)
+	}
+
+	return time.Duration(send) * time.Millisecond, time.Duration(receive) * time.Millisecond, nil
+}
+
+// negotiateHeartBeat returns the outgoing and incoming heart-beat intervals agreed
+// between client (cx, cy) and server (sx, sy) headers, zero where disabled
+func negotiateHeartBeat(cx, cy, sx, sy time.Duration) (time.Duration, time.Duration) {
+	var out, in time.Duration
+	if cx > 0 && sy > 0 {
+		out = maxDuration(cx, sy)
+	}
+	if cy > 0 && sx > 0 {
+		in = maxDuration(cy, sx)
+	}
+	return out, in
+}
+
+// maxDuration returns the larger of a and b
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,95 @@
+package stomp
+
+// AckMode represents a STOMP subscription acknowledgement mode
+type AckMode string
+
+// STOMP acknowledgement modes
+const (
+	AckAuto             = AckMode("auto")
+	AckClient           = AckMode("client")
+	AckClientIndividual = AckMode("client-individual")
+)
+
+// Subscription represents an active subscription to a destination
+type Subscription struct {
+	ID          string
+	Destination string
+	Ack         AckMode
+
+	client  *Client        // owning client
+	handler func(*Message) // received message handler
+}
+
+// frame returns the SUBSCRIBE frame for this subscription
+func (s *Subscription) frame() *Frame {
+	return NewFrame(CommandSubscribe,
+		"id", s.ID,
+		"destination", s.Destination,
+		"ack", string(s.Ack),
+	)
+}
+
+// Unsubscribe ends this subscription, no further messages are passed to its handler
+func (s *Subscription) Unsubscribe() error {
+	s.client.mu.Lock()
+	delete(s.client.subs, s.ID)
+	s.client.mu.Unlock()
+	return s.client.Write(NewFrame(CommandUnsubscribe, "id", s.ID))
+}
+
+// Message is a MESSAGE frame received for a subscription
+type Message struct {
+	*Frame
+	Subscription *Subscription
+}
+
+// Destination returns the destination the message was sent to
+func (m *Message) Destination() string {
+	return m.Get("destination")
+}
+
+// Ack acknowledges consumption of this message
+func (m *Message) Ack() error {
+	c := m.Subscription.client
+	return c.Write(c.ackFrame(CommandAck, m, ""))
+}
+
+// Nack indicates this message was not consumed
+func (m *Message) Nack() error {
+	c := m.Subscription.client
+	return c.Write(c.ackFrame(CommandNack, m, ""))
+}
+
+// Transaction represents a STOMP transaction, started by Client.Begin
+type Transaction struct {
+	ID string
+
+	client *Client // owning client
+}
+
+// Send sends body to destination within this transaction, with content type if set
+func (t *Transaction) Send(destination, contentType string, body []byte) error {
+	f := sendFrame(destination, contentType, body)
+	f.Set("transaction", t.ID)
+	return t.client.Write(f)
+}
+
+// Ack acknowledges consumption of msg within this transaction
+func (t *Transaction) Ack(msg *Message) error {
+	return t.client.Write(t.client.ackFrame(CommandAck, msg, t.ID))
+}
+
+// Nack indicates msg was not consumed within this transaction
+func (t *Transaction) Nack(msg *Message) error {
+	return t.client.Write(t.client.ackFrame(CommandNack, msg, t.ID))
+}
+
+// Commit commits this transaction
+func (t *Transaction) Commit() error {
+	return t.client.Write(NewFrame(CommandCommit, "transaction", t.ID))
+}
+
+// Abort rolls back this transaction
+func (t *Transaction) Abort() error {
+	return t.client.Write(NewFrame(CommandAbort, "transaction", t.ID))
+}
@@ -0,0 +1,270 @@
+package sockjsclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// frameReader reads the next single sockjs frame from a streaming response body
+type frameReader func(*bufio.Reader) ([]byte, error)
+
+// openStream performs the request opening a sockjs streaming endpoint,
+// returning the response (with body left open for reading) on success
+func openStream(ctx context.Context, client *http.Client, method, addr string, hdrs http.Header) (*http.Response, error) {
+	// Prepare stream request
+	req, err := http.NewRequestWithContext(ctx, method, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range hdrs {
+		req.Header[key] = values
+	}
+
+	// Perform stream request
+	rsp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch rsp.StatusCode {
+	// Success!
+	case 200:
+		return rsp, nil
+
+	// i.e. session not found --> closed
+	case 404:
+		rsp.Body.Close()
+		return nil, fmt.Errorf("%w (no close frame received)", ErrClosedConnection)
+
+	// Unexpected status code
+	default:
+		rsp.Body.Close()
+		return nil, fmt.Errorf("%w (HTTP %d)", ErrUnexpectedResponse, rsp.StatusCode)
+	}
+}
+
+// dialStream opens a sockjs streaming endpoint and validates the session
+// open frame, returning a running streamConn on success. The dial context
+// only bounds the opening of the session, not the lifetime of the conn
+func dialStream(ctx context.Context, client http.Client, method, saddr, waddr string, hdrs http.Header, next frameReader, events eventFunc, heartbeat time.Duration, in *inbox) (*streamConn, *http.Response, error) {
+	// Streams are long-lived, rely on heartbeats instead
+	sclient := client
+	sclient.Timeout = 0
+
+	// Create new connection cancel context, this
+	// is also cancelled should dial context be done
+	connCtx, cncl := context.WithCancel(context.Background())
+	opened := make(chan struct{})
+	defer close(opened)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Only cancel if not yet opened
+			select {
+			case <-opened:
+			default:
+				cncl()
+			}
+		case <-opened:
+		}
+	}()
+
+	// Attempt opening the stream
+	rsp, err := openStream(connCtx, &sclient, method, saddr, hdrs)
+	if err != nil {
+		cncl()
+		return nil, rsp, maskCtxCancelled(ctx, err)
+	}
+
+	// Read and validate initial message
+	r := bufio.NewReader(rsp.Body)
+	b, err := next(r)
+	if err != nil {
+		rsp.Body.Close()
+		cncl()
+		return nil, rsp, err
+	} else if mt, _, err := parseMessage(b); err != nil || mt != MessageTypeOpen {
+		rsp.Body.Close()
+		cncl()
+		return nil, rsp, fmt.Errorf("%w: opening sockjs session", ErrInvalidResponse)
+	}
+
+	// Create new connection, handing over open stream
+	conn := &streamConn{
+		client:    client,
+		sclient:   sclient,
+		method:    method,
+		saddr:     saddr,
+		waddr:     waddr,
+		hdrs:      hdrs,
+		next:      next,
+		body:      rsp.Body,
+		r:         r,
+		cncl:      cncl,
+		in:        in,
+		events:    events,
+		heartbeat: heartbeat,
+		ctx:       connCtx,
+	}
+	go conn.run()
+
+	return conn, rsp, nil
+}
+
+// streamConn represents a sockjs streaming client connection, receiving
+// frames from a long-lived HTTP response body that is reopened whenever
+// the server rotates the stream, and sending via XHR requests
+type streamConn struct {
+	client    http.Client     // our provided HTTP client
+	sclient   http.Client     // client copy used for opening streams
+	method    string          // HTTP method used to open stream
+	saddr     string          // prepared stream endpoint addr
+	waddr     string          // prepared XHR write endpoint addr
+	hdrs      http.Header     // headers to provide when opening stream
+	next      frameReader     // reads next frame from stream
+	body      io.ReadCloser   // currently open stream body
+	r         *bufio.Reader   // buffered reader over stream body
+	cncl      func()          // context cancel
+	in        *inbox          // inbound message queue
+	ctx       context.Context // Conn context
+	events    eventFunc       // lifecycle event hook
+	heartbeat time.Duration   // heartbeat timeout
+}
+
+// run starts the read loop and handles final error propagation
+func (conn *streamConn) run() {
+	// Start the read loop
+	err := conn.readLoop()
+	if err == nil {
+		panic("closed read loop with nil error")
+	}
+
+	// Propagate error (unless already failed), emitting disconnect before readers see it
+	conn.in.failWith(maskCtxCancelled(conn.ctx, err), func(err error) {
+		conn.events.emit(Event{Type: EventDisconnect, Err: err})
+	})
+}
+
+// readLoop is the main stream read routine, handling passing
+// of inbound messages ready to be received and stream rotation
+func (conn *streamConn) readLoop() error {
+	// ensure closed
+	defer func() {
+		conn.Close()
+		conn.body.Close()
+	}()
+
+	// Watch for missed heartbeats
+	watchdog := newWatchdog(conn.heartbeat, func() {
+		conn.in.abort(ErrNoHeartbeat)
+		conn.Close() // kill conn
+	})
+	defer watchdog.stop()
+
+	for {
+		// Read next frame from stream
+		b, err := conn.next(conn.r)
+		if err == io.EOF {
+			// Stream rotated by server, reopen
+			conn.body.Close()
+			rsp, err := openStream(conn.ctx, &conn.sclient, conn.method, conn.saddr, conn.hdrs)
+			if err != nil {
+				return err
+			}
+			conn.body = rsp.Body
+			conn.r = bufio.NewReader(rsp.Body)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		// Parse message type
+		watchdog.feed()
+		conn.events.frame(b)
+		mt, b, err := parseMessage(b)
+		if err != nil {
+			return err
+		}
+
+		switch mt {
+		// Parse message block, pass along
+		case MessageTypeData:
+			msgs, err := unmarshalMessages(b)
+			if err != nil {
+				return err
+			}
+			for _, msg := range msgs {
+				if err := conn.in.push(conn.ctx, []byte(msg)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// ReadMsg implements Conn.ReadMsg()
+func (conn *streamConn) ReadMsg() ([]byte, error) {
+	return conn.in.pop(context.Background())
+}
+
+// ReadMsgContext implements Conn.ReadMsgContext()
+func (conn *streamConn) ReadMsgContext(ctx context.Context) ([]byte, error) {
+	return conn.in.pop(ctx)
+}
+
+// WriteMsg implements Conn.WriteMsg()
+func (conn *streamConn) WriteMsg(data ...[]byte) error {
+	return conn.WriteMsgContext(context.Background(), data...)
+}
+
+// WriteMsgContext implements Conn.WriteMsgContext()
+func (conn *streamConn) WriteMsgContext(ctx context.Context, data ...[]byte) error {
+	// Check if already closed
+	if conn.ctx.Err() != nil {
+		return ErrClosedConnection
+	}
+
+	// Convert to message block
+	b, err := marshalMessages(data)
+	if err != nil {
+		return err
+	}
+
+	// Prepare write context, also checking conn status
+	wctx, cncl := joinContext(conn.ctx, ctx)
+	defer cncl()
+
+	// Perform the write request
+	if err := sendXHR(wctx, &conn.client, conn.waddr, b); err != nil {
+		// Check for cancelled write, conn stays open
+		if ctx.Err() != nil && conn.ctx.Err() == nil {
+			return ctx.Err()
+		}
+		conn.cncl() // ensure closed
+		return err
+	}
+
+	return nil
+}
+
+// Close implements Conn.Close()
+func (conn *streamConn) Close() error {
+	conn.cncl()
+	return nil
+}
+
+// Stats implements Conn.Stats(), with no websocket ping statistics available
+func (conn *streamConn) Stats() Stats {
+	return Stats{MessagesDropped: conn.in.droppedCount()}
+}
+
+// GetConnection implements Conn.GetConnection(), always nil as there is no websocket
+func (conn *streamConn) GetConnection() *websocket.Conn {
+	return nil
+}
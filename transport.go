@@ -0,0 +1,67 @@
+package sockjsclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transport represents a sockjs transport protocol, named as in sockjs-client
+type Transport string
+
+// Sockjs transports
+const (
+	TransportWebsocket    = Transport("websocket")
+	TransportXHRStreaming = Transport("xhr-streaming")
+	TransportEventSource  = Transport("eventsource")
+	TransportHTMLFile     = Transport("htmlfile")
+	TransportXHRPolling   = Transport("xhr-polling")
+	TransportJSONPPolling = Transport("jsonp-polling")
+)
+
+// DefaultTransports is the order in which transports are attempted by a Client
+// with no configured Transports, following the official sockjs transport ladder
+var DefaultTransports = []Transport{
+	TransportWebsocket,
+	TransportXHRStreaming,
+	TransportEventSource,
+	TransportHTMLFile,
+	TransportXHRPolling,
+	TransportJSONPPolling,
+}
+
+// ParseTransports parses a comma separated list of transport names, e.g.
+// "xhr-streaming,xhr-polling", as may be provided by environment or flags
+func ParseTransports(s string) ([]Transport, error) {
+	var transports []Transport
+	for _, name := range strings.Split(s, ",") {
+		// Skip empty entries
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		// Ensure a known transport
+		transport := Transport(name)
+		if !transport.valid() {
+			return nil, fmt.Errorf("sockjsclient: unknown transport %q", name)
+		}
+
+		transports = append(transports, transport)
+	}
+	return transports, nil
+}
+
+// valid returns whether this is a known transport
+func (t Transport) valid() bool {
+	return containsTransport(DefaultTransports, t)
+}
+
+// containsTransport returns whether transport is within transports
+func containsTransport(transports []Transport, transport Transport) bool {
+	for _, t := range transports {
+		if t == transport {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package sockjsclient_test
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestParseTransports(t *testing.T) {
+	for _, tc := range []struct {
+		s          string
+		transports []sockjsclient.Transport
+		err        bool
+	}{
+		{s: "xhr-streaming, xhr-polling", transports: []sockjsclient.Transport{sockjsclient.TransportXHRStreaming, sockjsclient.TransportXHRPolling}},
+		{s: ",websocket,,", transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket}},
+		{s: ""},
+		{s: "websocket,xhr", err: true},
+	} {
+		transports, err := sockjsclient.ParseTransports(tc.s)
+		if (err != nil) != tc.err || !reflect.DeepEqual(transports, tc.transports) {
+			t.Fatalf("parsed transports %q were not as expected: {Expect=%v,%v Got=%v,%v}", tc.s, tc.transports, tc.err, transports, err)
+		}
+	}
+}
+
+func TestClientTransportFallback(t *testing.T) {
+	srv := newRefusingServer(sockjsclient.TransportWebsocket, sockjsclient.TransportXHRStreaming)
+	defer srv.Close()
+
+	client := &sockjsclient.Client{
+		Address: srv.URL,
+		Transports: []sockjsclient.Transport{
+			sockjsclient.TransportWebsocket,
+			sockjsclient.TransportXHRStreaming,
+			sockjsclient.TransportXHRPolling,
+		},
+	}
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
+	defer client.Close()
+	if transport := client.Transport(); transport != sockjsclient.TransportXHRPolling {
+		t.Fatalf("expected fallback to xhr-polling, got %s", transport)
+	}
+}
+
+func TestClientTransportWhitelist(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	client := &sockjsclient.Client{
+		Address:             srv.URL,
+		WhitelistTransports: []sockjsclient.Transport{sockjsclient.TransportEventSource, sockjsclient.TransportXHRPolling},
+	}
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
+	defer client.Close()
+
+	// First whitelisted in order of preference, nothing else attempted
+	if transport := client.Transport(); transport != sockjsclient.TransportEventSource {
+		t.Fatalf("expected whitelisted eventsource, got %s", transport)
+	}
+	for _, req := range srv.Requests() {
+		if strings.HasSuffix(req.URL.Path, "/websocket") || strings.HasSuffix(req.URL.Path, "/xhr_streaming") {
+			t.Fatalf("expected only whitelisted transports attempted, got request %s", req.URL.Path)
+		}
+	}
+}
+
+func TestClientConnectError(t *testing.T) {
+	srv := newRefusingServer(sockjsclient.TransportWebsocket, sockjsclient.TransportXHRPolling)
+	defer srv.Close()
+
+	// Each failed attempt is aggregated in order
+	client := &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket, sockjsclient.TransportXHRPolling},
+	}
+	err := client.ConnectContext(testContext(t))
+	var cerr *sockjsclient.ConnectError
+	if !errors.As(err, &cerr) || !errors.Is(err, sockjsclient.ErrClientCannotConnect) {
+		t.Fatalf("expected ConnectError, got %v", err)
+	}
+	var attempted []sockjsclient.Transport
+	for _, attempt := range cerr.Attempts {
+		attempted = append(attempted, attempt.Transport)
+	}
+	if exp := client.Transports; !reflect.DeepEqual(attempted, exp) {
+		t.Fatalf("attempted transports were not as expected: {Expect=%v Attempted=%v}", exp, attempted)
+	}
+
+	// Attempt errors are matched, the first in order by errors.As
+	var terr *sockjsclient.TransportError
+	if !errors.As(err, &terr) || terr.Transport != sockjsclient.TransportWebsocket {
+		t.Fatalf("expected websocket TransportError, got %v", err)
+	} else if !errors.Is(err, sockjsclient.ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse from xhr attempt, got %v", err)
+	}
+
+	// None allowed, with websocket unavailable
+	nows := sockjstest.NewUnstartedServer()
+	nows.Info.WebSocket = false
+	nows.Start()
+	defer nows.Close()
+	client.Address = nows.URL
+	client.Transports = []sockjsclient.Transport{sockjsclient.TransportWebsocket}
+	err = client.ConnectContext(testContext(t))
+	if !errors.As(err, &cerr) || cerr.Info != nil || len(cerr.Attempts) != 0 {
+		t.Fatalf("expected ConnectError with no attempts, got %v", err)
+	}
+
+	// Info endpoint failure
+	client.Address = srv.URL + "/missing"
+	err = client.ConnectContext(testContext(t))
+	if !errors.As(err, &cerr) || cerr.Info == nil || !errors.Is(err, sockjsclient.ErrUnexpectedResponse) {
+		t.Fatalf("expected ConnectError for info, got %v", err)
+	}
+}
+
+// newRefusingServer returns a started test server answering 404 Not Found to any
+// request for the endpoints of the given transports
+func newRefusingServer(refused ...sockjsclient.Transport) *sockjstest.Server {
+	endpoints := map[sockjsclient.Transport]string{
+		sockjsclient.TransportWebsocket:    "/websocket",
+		sockjsclient.TransportXHRStreaming: "/xhr_streaming",
+		sockjsclient.TransportEventSource:  "/eventsource",
+		sockjsclient.TransportHTMLFile:     "/htmlfile",
+		sockjsclient.TransportXHRPolling:   "/xhr",
+		sockjsclient.TransportJSONPPolling: "/jsonp",
+	}
+	srv := sockjstest.NewUnstartedServer()
+	srv.HTTP.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, transport := range refused {
+			if strings.HasSuffix(r.URL.Path, endpoints[transport]) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		srv.ServeHTTP(w, r)
+	})
+	srv.Start()
+	return srv
+}
@@ -48,6 +48,22 @@ func maskCtxCancelled(ctx context.Context, err error) error {
 	return err
 }
 
+// joinContext returns a context (with values of parent) that is cancelled
+// when either parent or other is done, or the returned cancel is called
+func joinContext(parent, other context.Context) (context.Context, func()) {
+	ctx, cncl := context.WithCancel(parent)
+	if other.Done() != nil {
+		go func() {
+			select {
+			case <-other.Done():
+				cncl()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cncl
+}
+
 // isWebsocketClosed will check if this received websocket error indicates a closed connection
 func isWebsocketClosed(err error) bool {
 	_, ok := err.(*websocket.CloseError)
@@ -71,3 +87,13 @@ func paddedRandomIntn(max int) string {
 	}
 	return is
 }
+
+// newCallbackName returns a random script callback name for use by the JSONP and htmlfile transports
+func newCallbackName() string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = letters[rand.Intn(len(letters))]
+	}
+	return "_sockjs_" + string(b)
+}
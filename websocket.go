@@ -2,10 +2,12 @@ package sockjsclient
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,6 +17,17 @@ type WSDialer struct {
 	// Dialer is the underlying websocket dialer used
 	// by the produced websocket conn
 	Dialer *websocket.Dialer
+
+	// ConnOptions are the options common to all transports
+	ConnOptions
+
+	// PingInterval enables client-side liveness probing, sending a websocket ping
+	// at this interval with round trip times exposed via Conn.Stats(). Zero disables
+	PingInterval time.Duration
+
+	// PongTimeout is the time allowed for a pong reply to each ping before the
+	// conn fails with ErrNoPong. Zero means PingInterval
+	PongTimeout time.Duration
 }
 
 func (d *WSDialer) Dial(addr, serverID, sessionID string, hdrs http.Header, query map[string]string) (Conn, *http.Response, error) {
@@ -61,23 +74,45 @@ func (d *WSDialer) DialContext(ctx context.Context, addr, serverID, sessionID st
 	// Create new connection with cancel context
 	ctx, cncl := context.WithCancel(context.Background())
 	conn := &wsConn{
-		conn: ws,
-		in:   make(chan interface{}, 10),
-		cncl: cncl,
-		ctx:  ctx,
+		conn:      ws,
+		in:        newInbox(d.InboundBuffer, d.Overflow),
+		wmu:       make(chan struct{}, 1),
+		events:    d.events,
+		heartbeat: d.HeartbeatTimeout,
+		pong:      make(chan string, 1),
+		cncl:      cncl,
+		ctx:       ctx,
 	}
+	ws.SetPongHandler(conn.handlePong)
 	go conn.run()
 
+	// Start liveness probing if enabled
+	if d.PingInterval > 0 {
+		timeout := d.PongTimeout
+		if timeout <= 0 {
+			timeout = d.PingInterval
+		}
+		go conn.pingLoop(d.PingInterval, timeout)
+	}
+
 	return conn, rsp, nil
 }
 
 // wsConn wraps a websocket.Conn to add our own connection
 // tracking, error handling and context usage
 type wsConn struct {
-	conn *websocket.Conn  // underlying ws conn
-	in   chan interface{} // inbound data/error channel
-	cncl func()           // context cancel
-	ctx  context.Context  // conn context
+	conn      *websocket.Conn // underlying ws conn
+	in        *inbox          // inbound message queue
+	wmu       chan struct{}   // write lock, as only one writer allowed
+	cncl      func()          // context cancel
+	ctx       context.Context // conn context
+	events    eventFunc       // lifecycle event hook
+	heartbeat time.Duration   // heartbeat timeout
+	pong      chan string     // pong received notification, with payload
+	pushing   int32           // set while read loop is queueing a message, accessed atomically
+	pushed    int64           // time (unix nanos) read loop last finished queueing, accessed atomically
+	stats     Stats           // conn statistics
+	smu       sync.Mutex      // protects stats
 }
 
 // run starts the read loop and handles final error propagation
@@ -88,49 +123,24 @@ func (conn *wsConn) run() {
 		panic("closed read loop with nil error")
 	}
 
-	// Propagate err
-	conn.in <- maskCtxCancelled(conn.ctx, err)
+	// Propagate error (unless already failed), emitting disconnect before readers see it
+	conn.in.failWith(maskCtxCancelled(conn.ctx, err), func(err error) {
+		conn.events.emit(Event{Type: EventDisconnect, Err: err})
+	})
 }
 
 // readLoop is the main ws read routine, handling passing
 // of inbound messages ready to be received, and heartbeat checks
 func (conn *wsConn) readLoop() error {
-	const timeout = time.Hour * 240 // set connection to long running
+	// ensure closed
+	defer conn.Close()
 
-	heartbeat := make(chan struct{})
-	timer := time.NewTimer(timeout)
-	defer func() {
-		// ensure closed
-		conn.Close()
-
-		// drain timer
-		if !timer.Stop() {
-			<-timer.C
-		}
-
-		// stop heartbeat
-		close(heartbeat)
-	}()
-
-	go func() {
-		for {
-			select {
-			// Connected closed
-			case <-conn.ctx.Done():
-				return
-
-			// Timed out :(
-			case <-timer.C:
-				conn.Close() // kill conn
-				conn.in <- ErrNoHeartbeat
-				return
-
-			// Heartbeat received
-			case <-heartbeat:
-				timer.Reset(timeout)
-			}
-		}
-	}()
+	// Watch for missed heartbeats
+	watchdog := newWatchdog(conn.heartbeat, func() {
+		conn.in.abort(ErrNoHeartbeat)
+		conn.Close() // kill conn
+	})
+	defer watchdog.stop()
 
 	for {
 		// Read next websocket message
@@ -145,68 +155,79 @@ func (conn *wsConn) readLoop() error {
 		}
 
 		// Parse the received message
+		watchdog.feed()
+		conn.events.frame(b)
 		mt, b, err := parseMessage(b)
 		if err != nil {
 			return err
 		}
 
 		switch mt {
-		// Update heartbeat chan
-		case MessageTypeHeartbeat:
-			heartbeat <- struct{}{}
-
 		// Parse message block, pass along
 		case MessageTypeData:
-			msgs := []string{}
-			if err := json.Unmarshal(b, &msgs); err != nil {
+			msgs, err := unmarshalMessages(b)
+			if err != nil {
 				return err
 			}
 			for _, msg := range msgs {
-				conn.in <- []byte(msg)
+				if err := conn.push([]byte(msg)); err != nil {
+					return err
+				}
 			}
 		}
 	}
 }
 
+// push queues an inbound message, tracking the time spent doing so as pongs
+// are not read (and so cannot be waited on) while backpressure is applied
+func (conn *wsConn) push(msg []byte) error {
+	atomic.StoreInt32(&conn.pushing, 1)
+	defer func() {
+		atomic.StoreInt64(&conn.pushed, time.Now().UnixNano())
+		atomic.StoreInt32(&conn.pushing, 0)
+	}()
+	return conn.in.push(conn.ctx, msg)
+}
+
 // ReadMsg implements Conn.ReadMsg()
 func (conn *wsConn) ReadMsg() ([]byte, error) {
-	select {
-	// Next message received
-	case v := <-conn.in:
-		switch v := v.(type) {
-		case error:
-			return nil, v
-		case []byte:
-			return v, nil
-		default:
-			panic("unexpected type down inbound channel")
-		}
+	return conn.in.pop(context.Background())
+}
 
-	// Check if already closed
-	case <-conn.ctx.Done():
-		return nil, ErrClosedConnection
-	}
+// ReadMsgContext implements Conn.ReadMsgContext()
+func (conn *wsConn) ReadMsgContext(ctx context.Context) ([]byte, error) {
+	return conn.in.pop(ctx)
 }
 
 // WriteMsg implements Conn.WriteMsg()
 func (conn *wsConn) WriteMsg(data ...[]byte) error {
+	return conn.WriteMsgContext(context.Background(), data...)
+}
+
+// WriteMsgContext implements Conn.WriteMsgContext()
+func (conn *wsConn) WriteMsgContext(ctx context.Context, data ...[]byte) error {
 	// Check if already closed
 	if conn.ctx.Err() != nil {
 		return ErrClosedConnection
 	}
 
 	// Convert to message block
-	msgs := make([]string, 0, len(data))
-	for _, b := range data {
-		msgs = append(msgs, string(b))
-	}
-
-	// Marshal message block
-	b, err := json.Marshal(msgs)
+	b, err := marshalMessages(data)
 	if err != nil {
 		return err
 	}
 
+	// Acquire write lock, honouring ctx until
+	// the frame begins to be written
+	select {
+	case conn.wmu <- struct{}{}:
+		defer func() { <-conn.wmu }()
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-conn.ctx.Done():
+		return ErrClosedConnection
+	}
+
 	if err := conn.conn.WriteMessage(websocket.TextMessage, b); err != nil {
 		// Check for expected close
 		if conn.ctx.Err() != nil {
@@ -235,8 +256,8 @@ func (conn *wsConn) Close() error {
 	// Ensure canclled
 	defer conn.cncl()
 
-	// Attempt to send final close message
-	if err := conn.conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
+	// Attempt to send final close message (safe alongside concurrent writes)
+	if err := conn.conn.WriteControl(websocket.CloseMessage, []byte{}, time.Now().Add(time.Second)); err != nil {
 		if isWebsocketClosed(err) {
 			return nil // already closed
 		}
@@ -252,6 +273,122 @@ func (conn *wsConn) Close() error {
 	return nil
 }
 
+// pingLoop sends a websocket ping each interval, failing the conn should no pong
+// echoing its payload be received within timeout. Time the read loop spends
+// applying backpressure (during which pongs are not read) is not counted
+func (conn *wsConn) pingLoop(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		// Connection closed
+		case <-conn.ctx.Done():
+			return
+
+		// Time for next ping
+		case <-ticker.C:
+		}
+
+		// Discard any stale pong, e.g. one arriving after we last stopped waiting
+		select {
+		case <-conn.pong:
+		default:
+		}
+
+		// Send ping, timestamped for RTT measurement
+		sent := time.Now()
+		payload := strconv.FormatInt(sent.UnixNano(), 10)
+		if err := conn.conn.WriteControl(websocket.PingMessage, []byte(payload), sent.Add(timeout)); err != nil {
+			if conn.ctx.Err() == nil {
+				conn.in.abort(fmt.Errorf("%w: sending ping: %v", ErrNoPong, err))
+				conn.Close() // kill conn
+			}
+			return
+		}
+		conn.smu.Lock()
+		conn.stats.PingsSent++
+		conn.smu.Unlock()
+
+		if !conn.awaitPong(payload, sent, timeout) {
+			return
+		}
+	}
+}
+
+// awaitPong waits for a pong echoing payload of the ping sent at the given time,
+// returning false should the conn be closed or failed with ErrNoPong first
+func (conn *wsConn) awaitPong(payload string, sent time.Time, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		// Connection closed
+		case <-conn.ctx.Done():
+			return false
+
+		// Pong received, ignoring any not for this ping
+		case pong := <-conn.pong:
+			if pong == payload {
+				return true
+			}
+
+		// Timed out, unless read loop was (or still is) applying backpressure
+		case <-timer.C:
+			if atomic.LoadInt32(&conn.pushing) != 0 {
+				timer.Reset(timeout)
+				continue
+			}
+			if pushed := time.Unix(0, atomic.LoadInt64(&conn.pushed)); pushed.After(sent) {
+				if wait := time.Until(pushed.Add(timeout)); wait > 0 {
+					timer.Reset(wait)
+					continue
+				}
+			}
+			conn.in.abort(ErrNoPong)
+			conn.Close() // kill conn
+			return false
+		}
+	}
+}
+
+// handlePong is the websocket pong handler, recording round trip time
+// from the timestamped payload and notifying any waiting ping loop
+func (conn *wsConn) handlePong(payload string) error {
+	now := time.Now()
+
+	conn.smu.Lock()
+	conn.stats.PongsReceived++
+	conn.stats.LastPong = now
+	if nanos, err := strconv.ParseInt(payload, 10, 64); err == nil {
+		conn.stats.RTT = now.Sub(time.Unix(0, nanos))
+	}
+	conn.smu.Unlock()
+
+	// Notify ping loop (if waiting), replacing any unconsumed pong
+	for {
+		select {
+		case conn.pong <- payload:
+			return nil
+		default:
+		}
+		select {
+		case <-conn.pong:
+		default:
+		}
+	}
+}
+
+// Stats implements Conn.Stats()
+func (conn *wsConn) Stats() Stats {
+	conn.smu.Lock()
+	stats := conn.stats
+	conn.smu.Unlock()
+	stats.MessagesDropped = conn.in.droppedCount()
+	return stats
+}
+
 func (conn *wsConn) GetConnection() *websocket.Conn {
 	return conn.conn
 }
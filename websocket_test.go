@@ -0,0 +1,122 @@
+package sockjsclient_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rodneyVW/go-sockjsclient"
+	"github.com/rodneyVW/go-sockjsclient/sockjstest"
+)
+
+func TestWebsocketPingStats(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	client := &sockjsclient.Client{
+		Address:    srv.URL,
+		Transports: []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+		WSDialer:   &sockjsclient.WSDialer{PingInterval: time.Millisecond * 10},
+	}
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
+	defer client.Close()
+
+	// Wait for a few pings to be answered
+	deadline := time.Now().Add(time.Second * 5)
+	for client.Stats().PongsReceived < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected pongs received, got stats %+v", client.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	stats := client.Stats()
+	if stats.PingsSent < stats.PongsReceived {
+		t.Fatalf("expected no more pongs than pings, got stats %+v", stats)
+	} else if stats.RTT <= 0 || stats.LastPong.IsZero() {
+		t.Fatalf("expected round trip time measured, got stats %+v", stats)
+	}
+}
+
+func TestWebsocketPingBackpressure(t *testing.T) {
+	srv := sockjstest.NewServer()
+	defer srv.Close()
+
+	client := &sockjsclient.Client{
+		Address:       srv.URL,
+		Transports:    []sockjsclient.Transport{sockjsclient.TransportWebsocket},
+		InboundBuffer: 1,
+		WSDialer: &sockjsclient.WSDialer{
+			PingInterval: time.Millisecond * 10,
+			PongTimeout:  time.Millisecond * 20,
+		},
+	}
+	if err := client.ConnectContext(testContext(t)); err != nil {
+		t.Fatalf("error connecting to sockjs test server: %v", err)
+	}
+	defer client.Close()
+	session, err := srv.Accept(testContext(t))
+	if err != nil {
+		t.Fatalf("error accepting session: %v", err)
+	}
+
+	// Stall the read loop well beyond the pong timeout, pongs going unread
+	session.Send("1", "2", "3")
+	time.Sleep(time.Millisecond * 200)
+
+	for _, exp := range []string{"1", "2", "3"} {
+		msg, err := client.ReadMsgContext(testContext(t))
+		if err != nil {
+			t.Fatalf("error receiving message %q: %v", exp, err)
+		} else if string(msg) != exp {
+			t.Fatalf("message from server was not as expected: {Expect=%q Message=%q}", exp, msg)
+		}
+	}
+}
+
+func TestWebsocketPongMismatch(t *testing.T) {
+	// Server never answers pings, instead sending unsolicited pongs
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		ws.SetPingHandler(func(string) error { return nil })
+		if ws.WriteMessage(websocket.TextMessage, []byte("o")) != nil {
+			return
+		}
+		go func() {
+			for ws.WriteControl(websocket.PongMessage, []byte("unsolicited"), time.Now().Add(time.Second)) == nil {
+				time.Sleep(time.Millisecond * 2)
+			}
+		}()
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	dialer := &sockjsclient.WSDialer{
+		PingInterval: time.Millisecond * 10,
+		PongTimeout:  time.Millisecond * 50,
+	}
+	conn, _, err := dialer.DialContext(testContext(t), "ws"+strings.TrimPrefix(srv.URL, "http"), "000", "pongs", nil, nil)
+	if err != nil {
+		t.Fatalf("error dialing websocket: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ReadMsgContext(testContext(t)); !errors.Is(err, sockjsclient.ErrNoPong) {
+		t.Fatalf("expected ErrNoPong, got %v", err)
+	}
+}
@@ -3,7 +3,6 @@ package sockjsclient
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/gorilla/websocket"
 	"io/ioutil"
@@ -15,6 +14,19 @@ type XHRDialer struct {
 	// HTTPClient is the underlying http.Client used by
 	// the produced XHR conn
 	HTTPClient *http.Client
+
+	// RetryWindow enables retrying transient poll and send failures, i.e. network
+	// errors and 5xx responses, against the same session for up to this long
+	// before the conn fails. Sends are retried with the same payload, so are
+	// delivered at least once. Zero disables
+	RetryWindow time.Duration
+
+	// RetryDelay is the initial delay between retries, doubling with
+	// each retry after. Defaults to DefaultRetryDelay
+	RetryDelay time.Duration
+
+	// ConnOptions are the options common to all transports
+	ConnOptions
 }
 
 func (d *XHRDialer) Dial(addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error) {
@@ -68,12 +80,16 @@ func (d *XHRDialer) DialContext(ctx context.Context, addr, serverID, sessionID s
 	// Create new connection with cancel context
 	ctx, cncl := context.WithCancel(context.Background())
 	conn := &xhrConn{
-		client: *d.HTTPClient,
-		raddr:  readAddr,
-		waddr:  writeAddr,
-		cncl:   cncl,
-		in:     make(chan interface{}, 10),
-		ctx:    ctx,
+		client:    *d.HTTPClient,
+		raddr:     readAddr,
+		waddr:     writeAddr,
+		cncl:      cncl,
+		in:        newInbox(d.InboundBuffer, d.Overflow),
+		events:    d.events,
+		heartbeat: d.HeartbeatTimeout,
+		rwindow:   d.RetryWindow,
+		rdelay:    d.RetryDelay,
+		ctx:       ctx,
 	}
 	go conn.run()
 
@@ -83,12 +99,16 @@ func (d *XHRDialer) DialContext(ctx context.Context, addr, serverID, sessionID s
 // xhrConn represents a sockjs XHR client connection,
 // handling data passing, heartbeat and error tracking
 type xhrConn struct {
-	client http.Client      // our provided HTTP client
-	raddr  string           // prepared XHR read endpoint addr
-	waddr  string           // prepared XHR write endpoint addr
-	cncl   func()           // context cancel
-	in     chan interface{} // inbound data/error channel
-	ctx    context.Context  // Conn context
+	client    http.Client     // our provided HTTP client
+	raddr     string          // prepared XHR read endpoint addr
+	waddr     string          // prepared XHR write endpoint addr
+	cncl      func()          // context cancel
+	in        *inbox          // inbound message queue
+	ctx       context.Context // Conn context
+	events    eventFunc       // lifecycle event hook
+	heartbeat time.Duration   // heartbeat timeout
+	rwindow   time.Duration   // transient failure retry window
+	rdelay    time.Duration   // transient failure initial retry delay
 }
 
 // run starts the read loop and handles final error propagation
@@ -99,8 +119,10 @@ func (conn *xhrConn) run() {
 		panic("closed read loop with nil error")
 	}
 
-	// Propagate error
-	conn.in <- maskCtxCancelled(conn.ctx, err)
+	// Propagate error (unless already failed), emitting disconnect before readers see it
+	conn.in.failWith(maskCtxCancelled(conn.ctx, err), func(err error) {
+		conn.events.emit(Event{Type: EventDisconnect, Err: err})
+	})
 }
 
 // readLoop is the main xhr read routine, handling passing
@@ -117,6 +139,16 @@ func (conn *xhrConn) readLoop() error {
 	// ensure closed
 	defer conn.Close()
 
+	// Watch for missed heartbeats
+	watchdog := newWatchdog(conn.heartbeat, func() {
+		conn.in.abort(ErrNoHeartbeat)
+		conn.Close() // kill conn
+	})
+	defer watchdog.stop()
+
+	// Allow retrying transient poll failures
+	retry := retrier{window: conn.rwindow, delay: conn.rdelay}
+
 loop:
 	for {
 		// Prepare read request (addr is constant, but checks ctx status)
@@ -128,7 +160,10 @@ loop:
 		// Perform next read request
 		rsp, err := client.Do(req)
 		if err != nil {
-			return err
+			if conn.ctx.Err() == nil && isTransient(err) && retry.retry(conn.ctx) {
+				continue loop
+			}
+			return retry.giveUp(err)
 		}
 
 		switch rsp.StatusCode {
@@ -137,21 +172,33 @@ loop:
 
 		// i.e. session not found --> closed
 		case 404:
+			rsp.Body.Close()
 			return fmt.Errorf("%w (no close frame received)", ErrClosedConnection)
 
 		// Unexpected status code
 		default:
-			return fmt.Errorf("%w (HTTP %d)", ErrUnexpectedResponse, rsp.StatusCode)
+			rsp.Body.Close()
+			err := &statusError{code: rsp.StatusCode}
+			if isTransient(err) && retry.retry(conn.ctx) {
+				continue loop
+			}
+			return retry.giveUp(err)
 		}
 
 		// Read response body and close
 		b, err := ioutil.ReadAll(rsp.Body)
 		rsp.Body.Close()
 		if err != nil {
-			return err
+			if conn.ctx.Err() == nil && isTransient(err) && retry.retry(conn.ctx) {
+				continue loop
+			}
+			return retry.giveUp(err)
 		}
+		retry.reset()
 
 		// Parse message type
+		watchdog.feed()
+		conn.events.frame(b)
 		mt, b, err := parseMessage(b)
 		if err != nil {
 			return err
@@ -164,12 +211,14 @@ loop:
 
 		// Parse message block, pass along
 		case MessageTypeData:
-			msgs := []string{}
-			if err := json.Unmarshal(b, &msgs); err != nil {
+			msgs, err := unmarshalMessages(b)
+			if err != nil {
 				return err
 			}
 			for _, msg := range msgs {
-				conn.in <- []byte(msg)
+				if err := conn.in.push(conn.ctx, []byte(msg)); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -177,55 +226,83 @@ loop:
 
 // ReadMsg implements Conn.ReadMsg()
 func (conn *xhrConn) ReadMsg() ([]byte, error) {
-	select {
-	// Next message received
-	case v := <-conn.in:
-		switch v := v.(type) {
-		case error:
-			return nil, v
-		case []byte:
-			return v, nil
-		default:
-			panic("unexpected type down inbound channel")
-		}
+	return conn.in.pop(context.Background())
+}
 
-		// Check if already closed
-	case <-conn.ctx.Done():
-		return nil, ErrClosedConnection
-	}
+// ReadMsgContext implements Conn.ReadMsgContext()
+func (conn *xhrConn) ReadMsgContext(ctx context.Context) ([]byte, error) {
+	return conn.in.pop(ctx)
 }
 
 // WriteMsg implements Conn.WriteMsg()
 func (conn *xhrConn) WriteMsg(data ...[]byte) error {
+	return conn.WriteMsgContext(context.Background(), data...)
+}
+
+// WriteMsgContext implements Conn.WriteMsgContext()
+func (conn *xhrConn) WriteMsgContext(ctx context.Context, data ...[]byte) error {
 	// Check if already closed
 	if conn.ctx.Err() != nil {
 		return ErrClosedConnection
 	}
 
 	// Convert to message block
-	msgs := make([]string, 0, len(data))
-	for _, b := range data {
-		msgs = append(msgs, string(b))
+	b, err := marshalMessages(data)
+	if err != nil {
+		return err
 	}
 
-	// Marshal message block
-	b, err := json.Marshal(msgs)
+	// Prepare write context, also checking conn status
+	wctx, cncl := joinContext(conn.ctx, ctx)
+	defer cncl()
+
+	// Perform the write request, retrying transient failures
+	retry := retrier{window: conn.rwindow, delay: conn.rdelay}
+	err = sendXHR(wctx, &conn.client, conn.waddr, b)
+	for err != nil && wctx.Err() == nil && isTransient(err) && retry.retry(wctx) {
+		err = sendXHR(wctx, &conn.client, conn.waddr, b)
+	}
 	if err != nil {
-		return err
+		// Check for cancelled write, conn stays open
+		if ctx.Err() != nil && conn.ctx.Err() == nil {
+			return ctx.Err()
+		}
+		conn.cncl() // ensure closed
+		return retry.giveUp(err)
 	}
 
+	return nil
+}
+
+// Close implements Conn.Close()
+func (conn *xhrConn) Close() error {
+	conn.cncl()
+	return nil
+}
+
+// Stats implements Conn.Stats(), with no websocket ping statistics available
+func (conn *xhrConn) Stats() Stats {
+	return Stats{MessagesDropped: conn.in.droppedCount()}
+}
+
+// GetConnection implements Conn.GetConnection(), always nil as there is no websocket
+func (conn *xhrConn) GetConnection() *websocket.Conn {
+	return nil
+}
+
+// sendXHR posts a sockjs message block to the given XHR write endpoint addr.
+// Any error returned indicates the sockjs session should be considered closed
+func sendXHR(ctx context.Context, client *http.Client, waddr string, b []byte) error {
 	// Prepare new write request (addr is constant, but checks ctx status)
-	req, err := http.NewRequestWithContext(conn.ctx, "POST", conn.waddr, bytes.NewReader(b))
+	req, err := http.NewRequestWithContext(ctx, "POST", waddr, bytes.NewReader(b))
 	if err != nil {
-		conn.cncl() // ensure closed
-		return maskCtxCancelled(conn.ctx, err)
+		return maskCtxCancelled(ctx, err)
 	}
 
-	// Prepare and perform the write request
-	rsp, err := conn.client.Do(req)
+	// Perform the write request
+	rsp, err := client.Do(req)
 	if err != nil {
-		conn.cncl() // ensure closed
-		return maskCtxCancelled(conn.ctx, err)
+		return maskCtxCancelled(ctx, err)
 	}
 	defer rsp.Body.Close()
 
@@ -236,23 +313,10 @@ func (conn *xhrConn) WriteMsg(data ...[]byte) error {
 
 	// i.e. session not found --> closed
 	case 404:
-		conn.cncl() // ensure closed
 		return ErrClosedConnection
 
 	// Unexpected status code
 	default:
-		conn.cncl() // ensure closed
-		return fmt.Errorf("%w (HTTP %d)", ErrUnexpectedResponse, rsp.StatusCode)
+		return &statusError{code: rsp.StatusCode}
 	}
 }
-
-// Close implements Conn.Close()
-func (conn *xhrConn) Close() error {
-	conn.cncl()
-	return nil
-}
-
-func (conn *xhrConn) GetConnection() *websocket.Conn {
-	//TODO implement me
-	panic("implement me")
-}
@@ -0,0 +1,83 @@
+package sockjsclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+)
+
+type XHRStreamingDialer struct {
+	// HTTPClient is the underlying http.Client used by
+	// the produced XHR streaming conn
+	HTTPClient *http.Client
+
+	// ConnOptions are the options common to all transports
+	ConnOptions
+}
+
+func (d *XHRStreamingDialer) Dial(addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error) {
+	return d.DialContext(context.Background(), addr, serverID, sessionID, hdrs)
+}
+
+func (d *XHRStreamingDialer) DialContext(ctx context.Context, addr, serverID, sessionID string, hdrs http.Header) (Conn, *http.Response, error) {
+	// Parse a valid transport address
+	taddr, err := parseTransportAddr(addr, serverID, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Ensure an HTTP client is set
+	if d.HTTPClient == nil {
+		d.HTTPClient = http.DefaultClient
+	}
+
+	// Prepare connection endpoints
+	readAddr := taddr + "/xhr_streaming"
+	writeAddr := taddr + "/xhr_send"
+
+	// Attempt opening streaming connection
+	conn, rsp, err := dialStream(
+		ctx,
+		*d.HTTPClient,
+		http.MethodPost,
+		readAddr,
+		writeAddr,
+		hdrs,
+		readStreamingFrame,
+		d.events,
+		d.HeartbeatTimeout,
+		newInbox(d.InboundBuffer, d.Overflow),
+	)
+	if err != nil {
+		return nil, rsp, err
+	}
+
+	return conn, rsp, nil
+}
+
+// readStreamingFrame reads the next newline delimited frame from an
+// xhr_streaming response body, skipping over the 2KB 'h' prelude
+func readStreamingFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		// Read next full line from stream
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		// Skip empty lines and the prelude
+		if len(line) == 0 || isStreamingPrelude(line) {
+			continue
+		}
+
+		return line, nil
+	}
+}
+
+// isStreamingPrelude returns whether line is the run of 'h' bytes sent by
+// the server to flush proxy buffers, distinct from a single 'h' heartbeat
+func isStreamingPrelude(line []byte) bool {
+	return len(line) > 1 && bytes.Count(line, []byte{'h'}) == len(line)
+}
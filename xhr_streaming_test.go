@@ -0,0 +1,23 @@
+package sockjsclient
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadStreamingFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader(strings.Repeat("h", 2048) + "\no\n\nh\r\na[\"x\"]\n"))
+	for _, exp := range []string{"o", "h", `a["x"]`} {
+		frame, err := readStreamingFrame(r)
+		if err != nil {
+			t.Fatalf("error reading frame %q: %v", exp, err)
+		} else if string(frame) != exp {
+			t.Fatalf("frame was not as expected: {Expect=%q Frame=%q}", exp, frame)
+		}
+	}
+	if _, err := readStreamingFrame(r); err != io.EOF {
+		t.Fatalf("expected EOF at end of stream, got %v", err)
+	}
+}